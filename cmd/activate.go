@@ -3,18 +3,28 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	activateShell  string
+	activateFormat string
+)
+
 var activateCmd = &cobra.Command{
 	Use:   "activate",
 	Short: "Activate the ESP-IDF environment for the current project",
-	Long:  `Automatically sets up the ESP-IDF environment variables for the version specified in .espidf-version`,
+	Long: `Automatically sets up the ESP-IDF environment variables for the version specified in .espidf-version.
+Prints commands for the given shell (auto-detected by default), or the variables in a data
+format for IDEs, Makefiles and Docker --env-file.`,
+	Example: `  eval "$(idfmgr activate)"
+  idfmgr activate --shell fish | source
+  idfmgr activate --shell powershell | Out-String | Invoke-Expression
+  idfmgr activate --format dotenv > .env
+  idfmgr activate --format json`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := activateProject(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error activating ESP-IDF environment: %v\n", err)
@@ -24,74 +34,61 @@ var activateCmd = &cobra.Command{
 }
 
 func init() {
+	activateCmd.Flags().StringVar(&activateShell, "shell", "", "Shell to print commands for: "+strings.Join(supportedShells, ", ")+" (auto-detected by default)")
+	activateCmd.Flags().StringVar(&activateFormat, "format", "", "Print the variables as data instead: "+strings.Join(supportedEnvFormats, ", "))
+	activateCmd.MarkFlagsMutuallyExclusive("shell", "format")
 	rootCmd.AddCommand(activateCmd)
 }
 
 func activateProject() error {
-	versionFile := ".espidf-version"
-	versionData, err := os.ReadFile(versionFile)
+	_, idfPath, err := resolveProjectIDF()
 	if err != nil {
-		return fmt.Errorf("could not read %s: %w", versionFile, err)
-	}
-	version := strings.TrimSpace(string(versionData))
-
-	idfPath := filepath.Join(getESPBase(), version)
-	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
-		return fmt.Errorf("ESP-IDF path does not exist: %s", idfPath)
+		return err
 	}
 
-	switch runtime.GOOS {
-	case "windows":
-		return activateWindows(idfPath)
-	default:
-		return activateUnix(idfPath)
-	}
+	return printActivation(idfPath)
 }
 
-func activateUnix(idfPath string) error {
-	envVars, err := getESPIDFEnvironment(idfPath)
+func printActivation(idfPath string) error {
+	recordVersionUse(idfPath)
+
+	delta, err := loadEnvDelta(idfPath)
 	if err != nil {
 		return err
 	}
 
-	for _, e := range envVars {
-		if e == "" || !strings.Contains(e, "=") {
-			continue
+	if activateFormat != "" {
+		vars := make(map[string]string, len(delta.Vars)+1)
+		for key, value := range delta.Vars {
+			vars[key] = value
 		}
+		vars["PATH"] = envListToMap(applyEnvDelta(os.Environ(), delta))["PATH"]
 
-		parts := strings.SplitN(e, "=", 2)
-		key := parts[0]
-		val := parts[1]
-
-		if !isValidEnvKey(key) {
-			continue
+		output, err := renderEnvFormat(activateFormat, vars)
+		if err != nil {
+			return err
 		}
-
-		val = strings.ReplaceAll(val, `'`, `'\''`)
-		fmt.Printf("export %s='%s'\n", key, val)
+		fmt.Print(output)
+		return nil
 	}
 
-	fmt.Println("# To activate, run in your shell:")
-	fmt.Println("# eval $(idfmgr activate)")
-	return nil
-}
-
-func activateWindows(idfPath string) error {
-	exportBat := filepath.Join(idfPath, "export.bat")
-	if _, err := os.Stat(exportBat); os.IsNotExist(err) {
-		return fmt.Errorf("could not find export.bat in %s", idfPath)
+	shell, err := resolveShell(activateShell)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Launching new PowerShell with ESP-IDF environment set...")
-
-	psCommand := fmt.Sprintf("cmd /c \"%s && powershell\"", exportBat)
+	current := envListToMap(os.Environ())
+	target, err := activatedEnvironment(current, delta, filepath.Base(idfPath))
+	if err != nil {
+		return err
+	}
 
-	cmd := exec.Command("powershell.exe", "-NoExit", "-Command", psCommand)
-	cmd.Dir = idfPath
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	fmt.Print(renderShellChanges(shell, diffEnvMaps(current, target)))
+	fmt.Println(renderShellComment(shell, "To activate, run in your shell:"))
+	fmt.Println(renderShellComment(shell, shellActivateUsage(shell)))
+	fmt.Println(renderShellComment(shell, "To restore the previous environment:"))
+	fmt.Println(renderShellComment(shell, shellDeactivateUsage(shell)))
+	return nil
 }
 
 func isValidEnvKey(key string) bool {
@@ -108,4 +105,4 @@ func isValidEnvKey(key string) bool {
 		}
 	}
 	return true
-}
\ No newline at end of file
+}
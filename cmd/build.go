@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,16 +11,38 @@ import (
 )
 
 var (
-	useClang bool
+	useClang       bool
+	buildToolchain string
+	buildProfile   string
+	buildTargets   string
+	buildIDF       string
+	buildJobs      int
+	buildNoSize    bool
+	buildWerrorNew bool
+	buildSaveWarns bool
 )
 
 var buildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Build the ESP-IDF project",
-	Long:  `Build the current project using either GCC (default) or Clang toolchain`,
-	Args:  cobra.NoArgs,
+	Long: `Build the current project with the project's toolchain (GCC unless idfmgr.toml sets
+another one) or the one given with --toolchain. Each toolchain builds in its own directory.
+With --profile, the build uses the sdkconfig defaults declared for that profile in
+idfmgr.toml and gets its own build directory (build-<profile>/) and sdkconfig.<profile>.
+With --targets, each target builds in its own build directory (build-<target>/) with its
+own sdkconfig.<target>, and the build ends with a summary of all targets.
+With --idf, the project is built once per installed ESP-IDF version (build-<version>/),
+without changing .espidf-version, and the build ends with a compatibility matrix.
+Compiler warnings and errors are summarised after the build and written to warnings.txt
+and warnings.json.`,
+	Args: cobra.NoArgs,
 	Example: `  idfmgr build
-  idfmgr build --clang`,
+  idfmgr build --toolchain clang
+  idfmgr build --profile release
+  idfmgr build --targets esp32,esp32s3,esp32c3
+  idfmgr build --idf v5.1,v5.3
+  idfmgr build --idf all-installed
+  idfmgr build --werror-new`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := buildProject(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error building project: %v\n", err)
@@ -29,65 +52,100 @@ var buildCmd = &cobra.Command{
 }
 
 func init() {
-	buildCmd.Flags().BoolVar(&useClang, "clang", false, "Build with Clang toolchain")
+	buildCmd.Flags().StringVar(&buildToolchain, "toolchain", "", "Toolchain to build with: gcc or clang (default: from idfmgr.toml, or gcc)")
+	buildCmd.Flags().BoolVar(&useClang, "clang", false, "Build with Clang toolchain (same as --toolchain clang)")
+	buildCmd.Flags().StringVar(&buildProfile, "profile", "", "Build profile declared in idfmgr.toml")
+	buildCmd.Flags().StringVar(&buildTargets, "targets", "", "Comma-separated targets to build, each in its own build directory")
+	buildCmd.Flags().StringVar(&buildIDF, "idf", "", "Comma-separated installed ESP-IDF versions to build with (e.g. v5.1,v5.3), or all-installed")
+	buildCmd.Flags().IntVarP(&buildJobs, "jobs", "j", 0, "Number of builds to run in parallel with --targets or --idf (default: all)")
+	buildCmd.Flags().BoolVar(&buildNoSize, "no-size", false, "Don't print the firmware size after the build")
+	buildCmd.Flags().BoolVar(&buildWerrorNew, "werror-new", false, "Fail on warnings that are not in warnings-baseline.json")
+	buildCmd.Flags().BoolVar(&buildSaveWarns, "save-warnings-baseline", false, "Save the build's warnings to warnings-baseline.json")
+	buildCmd.RegisterFlagCompletionFunc("toolchain", completeToolchains)
+	buildCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	buildCmd.RegisterFlagCompletionFunc("targets", completeTargetList)
+	buildCmd.RegisterFlagCompletionFunc("idf", completeVersionList)
 	rootCmd.AddCommand(buildCmd)
 }
 
 func buildProject() error {
-	versionFile := ".espidf-version"
-	if _, err := os.Stat(versionFile); os.IsNotExist(err) {
-		return fmt.Errorf(".espidf-version file not found. Are you in an ESP-IDF project directory?")
+	if buildTargets != "" || buildIDF != "" {
+		if buildWerrorNew || buildSaveWarns {
+			return fmt.Errorf("--werror-new and --save-warnings-baseline cannot be combined with --targets or --idf")
+		}
+		return buildProjectMatrix()
 	}
 
-	versionData, err := os.ReadFile(versionFile)
+	_, idfPath, err := resolveProjectIDF()
 	if err != nil {
-		return fmt.Errorf("failed to read .espidf-version: %w", err)
+		return err
 	}
-	version := string(versionData)
-	version = version[:len(version)-1]
 
-	idfPath := filepath.Join(getESPBase(), version)
-	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
-		return fmt.Errorf("ESP-IDF version %s is not installed. Install it with: idfmgr install %s", version, version)
+	manifest, err := getProjectManifest()
+	if err != nil {
+		return err
 	}
 
 	env, err := getESPIDFEnvironment(idfPath)
 	if err != nil {
 		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
 	}
+	env = applyManifestEnv(env, manifest)
 
 	idfPyPath := filepath.Join(idfPath, "tools", "idf.py")
-	
-	var cmdArgs []string
-	var buildDir string
-
-	if useClang {
-		buildDir = "build-clang"
-		fmt.Println("Building with Clang toolchain...")
-		cmdArgs = []string{
-			idfPyPath,
-			"-DIDF_TOOLCHAIN=clang",
-			"-B", buildDir,
-			"build",
-		}
-	} else {
-		buildDir = "build"
-		fmt.Println("Building with GCC toolchain...")
-		cmdArgs = []string{
-			idfPyPath,
-			"build",
-		}
+	projectDir := getProjectDir()
+
+	toolchain, err := toolchainFlag(buildToolchain, useClang)
+	if err != nil {
+		return err
+	}
+	config, err := resolveBuildConfig(manifest, buildProfile, toolchain, projectDir)
+	if err != nil {
+		return err
+	}
+	env = config.applyEnv(env)
+	if err := config.Toolchain.checkInstalled(env, toolchainTarget(config.Target, projectDir)); err != nil {
+		return err
 	}
 
+	if err := runManifestHook(manifest, "pre-build", projectDir, env); err != nil {
+		return err
+	}
+
+	buildDir := filepath.Join(projectDir, config.BuildDir)
+	hadBuild := pathExists(filepath.Join(buildDir, "build.ninja"))
+	cmdArgs := append([]string{idfPyPath}, config.idfPyArgs()...)
+	if (buildWerrorNew || buildSaveWarns) && !hasWarningsRecord(buildDir) {
+		fmt.Printf("No warnings recorded for %s/ yet, rebuilding it completely to collect them\n", config.BuildDir)
+		cmdArgs = append(cmdArgs, "clean")
+		hadBuild = false
+	}
+	cmdArgs = append(cmdArgs, "build")
+
+	fmt.Printf("Building with %s...\n", config.description())
+	diagnostics := newDiagnosticCollector(projectDir)
 	cmd := exec.Command("python3", cmdArgs...)
+	cmd.Dir = projectDir
 	cmd.Env = env
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = io.MultiWriter(os.Stdout, diagnostics.stream())
+	cmd.Stderr = io.MultiWriter(os.Stderr, diagnostics.stream())
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("build failed: %w", err)
+	buildErr := cmd.Run()
+	succeeded := buildErr == nil
+	if err := reportDiagnostics(diagnostics, projectDir, buildDir, hadBuild, buildWerrorNew && succeeded, buildSaveWarns && succeeded); err != nil && succeeded {
+		return err
+	}
+	if buildErr != nil {
+		return fmt.Errorf("build failed: %w", buildErr)
+	}
+
+	if err := runManifestHook(manifest, "post-build", projectDir, env); err != nil {
+		return err
 	}
 
-	fmt.Printf("Build successful! Output in %s/\n", buildDir)
-	return nil
+	fmt.Printf("Build successful! Output in %s/\n", config.BuildDir)
+	if buildNoSize {
+		return nil
+	}
+	return reportBuildSize(manifest, idfPath, env, projectDir, config)
 }
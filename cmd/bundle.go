@@ -0,0 +1,866 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const bundleManifestFile = "manifest.json"
+
+type BundleManifest struct {
+	Version       string            `json:"version"`
+	Platform      string            `json:"platform"`
+	Targets       []string          `json:"targets"`
+	Clang         bool              `json:"clang"`
+	PythonVersion string            `json:"python_version"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Files         map[string]string `json:"files"`
+}
+
+type bundleToolsFile struct {
+	Tools []struct {
+		Name             string                       `json:"name"`
+		Install          string                       `json:"install"`
+		SupportedTargets []string                     `json:"supported_targets"`
+		Versions         []map[string]json.RawMessage `json:"versions"`
+		PlatformOverride []struct {
+			Platforms []string `json:"platforms"`
+			Install   string   `json:"install"`
+		} `json:"platform_overrides"`
+	} `json:"tools"`
+}
+
+var (
+	bundleTargets   string
+	bundleOutput    string
+	bundleSkipClang bool
+	bundleNoGit     bool
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Create and install offline ESP-IDF bundles",
+	Long: `Package an installed ESP-IDF version with its tools and Python wheels into a single
+archive that can be installed on machines without internet access.`,
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create <version>",
+	Short: "Create an offline bundle of an installed ESP-IDF version",
+	Long: `Create a single archive containing the ESP-IDF tree, the tool distributions for the
+selected targets and the Python wheels, together with a checksum manifest. The bundle
+can only be installed on the same platform and Python version it was created on.`,
+	Args: cobra.ExactArgs(1),
+	Example: `  idfmgr bundle create v5.1.2
+  idfmgr bundle create v5.1.2 --targets esp32,esp32s3 -o idf-v5.1.2.tar.gz
+  idfmgr bundle create v5.1.2 --no-git --skip-clang`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := createBundle(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating bundle: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	ValidArgsFunction: completeFirstArg(completeInstalledVersions),
+}
+
+var bundleInstallCmd = &cobra.Command{
+	Use:     "install <file>",
+	Short:   "Install ESP-IDF from an offline bundle",
+	Long:    `Verify and install an ESP-IDF version from a bundle without network access`,
+	Args:    cobra.ExactArgs(1),
+	Example: `  idfmgr bundle install idf-v5.1.2-linux-amd64.tar.gz`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := installBundle(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error installing bundle: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	bundleCreateCmd.Flags().StringVar(&bundleTargets, "targets", "", "Comma-separated targets to include tools for (default: targets of the installation)")
+	bundleCreateCmd.Flags().StringVarP(&bundleOutput, "output", "o", "", "Output file (default: idf-<version>-<platform>.tar.gz)")
+	bundleCreateCmd.Flags().BoolVar(&bundleSkipClang, "skip-clang", false, "Don't include esp-clang")
+	bundleCreateCmd.Flags().BoolVar(&bundleNoGit, "no-git", false, "Leave out the .git directory to make the bundle smaller")
+	bundleCreateCmd.RegisterFlagCompletionFunc("targets", completeTargetList)
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCmd.AddCommand(bundleInstallCmd)
+	rootCmd.AddCommand(bundleCmd)
+}
+
+func createBundle(version string) error {
+	idfPath := filepath.Join(getESPBase(), version)
+	if !isValidESPIDFInstall(idfPath) {
+		return fmt.Errorf("ESP-IDF version %s is not installed. Install it with: idfmgr install %s", version, version)
+	}
+
+	platform, err := getIDFToolsPlatform()
+	if err != nil {
+		return err
+	}
+
+	targets := []string{"esp32"}
+	clang := !bundleSkipClang
+	if manifest, err := readInstallManifest(idfPath); err == nil {
+		targets = manifest.Targets
+		clang = clang && manifest.Clang
+	}
+	if bundleTargets != "" {
+		targets = strings.Split(bundleTargets, ",")
+	}
+
+	output := bundleOutput
+	if output == "" {
+		output = fmt.Sprintf("idf-%s-%s.tar.gz", version, platform)
+	}
+
+	toolsPath := getIDFToolsPath()
+	pythonEnv, pythonVersion, err := findPythonEnv(toolsPath, version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Creating offline bundle of ESP-IDF %s for %s (targets: %s)...\n", version, platform, strings.Join(targets, ","))
+
+	if err := downloadBundleTools(idfPath, targets, clang); err != nil {
+		return err
+	}
+
+	distFiles, err := getBundleDistFiles(idfPath, toolsPath, platform, targets, clang)
+	if err != nil {
+		return err
+	}
+
+	stagingPath, err := os.MkdirTemp("", "idfmgr-bundle-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	wheelsPath := filepath.Join(stagingPath, "wheels")
+	if err := downloadBundleWheels(idfPath, toolsPath, pythonEnv, wheelsPath); err != nil {
+		return err
+	}
+
+	manifest := BundleManifest{
+		Version:       version,
+		Platform:      platform,
+		Targets:       targets,
+		Clang:         clang,
+		PythonVersion: pythonVersion,
+		CreatedAt:     time.Now(),
+		Files:         make(map[string]string),
+	}
+
+	size, err := writeArchive(output, func(tw *tar.Writer) error {
+		fmt.Println("Adding ESP-IDF tree...")
+		if err := addTreeToBundle(tw, idfPath, "idf", manifest.Files); err != nil {
+			return err
+		}
+		if bundleNoGit {
+			if err := addBytesToBundle(tw, "idf/version.txt", []byte(version+"\n"), manifest.Files); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Adding %d tool distribution(s)...\n", len(distFiles))
+		for _, distFile := range distFiles {
+			if err := addFileToBundle(tw, distFile, path.Join("dist", filepath.Base(distFile)), manifest.Files); err != nil {
+				return err
+			}
+		}
+
+		fmt.Println("Adding Python wheels...")
+		if err := addTreeToBundle(tw, wheelsPath, "wheels", manifest.Files); err != nil {
+			return err
+		}
+
+		if constraints := getConstraintsFile(toolsPath, version); pathExists(constraints) {
+			if err := addFileToBundle(tw, constraints, path.Join("constraints", filepath.Base(constraints)), manifest.Files); err != nil {
+				return err
+			}
+		}
+
+		manifestData, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		return addBytesToBundle(tw, bundleManifestFile, manifestData, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bundle created: %s (%s, %d files)\n", output, formatBytes(size), len(manifest.Files))
+	fmt.Printf("Install it offline with: idfmgr bundle install %s\n", filepath.Base(output))
+	return nil
+}
+
+func installBundle(bundlePath string) error {
+	espBase := getESPBase()
+	if err := os.MkdirAll(espBase, 0o755); err != nil {
+		return fmt.Errorf("failed to create ESP_BASE directory: %w", err)
+	}
+
+	manifest, err := readBundleManifest(bundlePath)
+	if err != nil {
+		return err
+	}
+
+	platform, err := getIDFToolsPlatform()
+	if err != nil {
+		return err
+	}
+	if manifest.Platform != platform {
+		return fmt.Errorf("bundle was created for %s, this machine is %s", manifest.Platform, platform)
+	}
+
+	installPath := filepath.Join(espBase, manifest.Version)
+	if _, err := os.Stat(installPath); err == nil {
+		fmt.Printf("Version %s is already installed at %s\n", manifest.Version, installPath)
+		return nil
+	}
+
+	if pythonVersion := getSystemPythonVersion(); manifest.PythonVersion != "" && pythonVersion != "" && pythonVersion != manifest.PythonVersion {
+		fmt.Printf("Warning: Bundle was created with Python %s, this machine has Python %s. Installing Python packages may fail.\n",
+			manifest.PythonVersion, pythonVersion)
+	}
+
+	stagingPath, err := os.MkdirTemp(espBase, ".bundle-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	fmt.Printf("Extracting %s...\n", bundlePath)
+	if err := extractBundle(bundlePath, stagingPath, manifest.Files); err != nil {
+		return err
+	}
+
+	fmt.Printf("Verifying %d file(s)...\n", len(manifest.Files))
+	if err := verifyBundleFiles(stagingPath, manifest.Files); err != nil {
+		return err
+	}
+
+	toolsPath := getIDFToolsPath()
+	if err := copyBundleDir(filepath.Join(stagingPath, "dist"), filepath.Join(toolsPath, "dist")); err != nil {
+		return fmt.Errorf("failed to copy tool distributions: %w", err)
+	}
+	if err := copyBundleDir(filepath.Join(stagingPath, "constraints"), toolsPath); err != nil {
+		return fmt.Errorf("failed to copy constraints file: %w", err)
+	}
+
+	if err := os.Rename(filepath.Join(stagingPath, "idf"), installPath); err != nil {
+		return fmt.Errorf("failed to move ESP-IDF into place: %w", err)
+	}
+
+	offlineEnv := []string{
+		"PIP_NO_INDEX=1",
+		"PIP_FIND_LINKS=" + filepath.Join(stagingPath, "wheels"),
+	}
+
+	if err := runInstallScript(os.Stdout, installPath, manifest.Targets, offlineEnv); err != nil {
+		os.RemoveAll(installPath)
+		return fmt.Errorf("failed to run install script: %w", err)
+	}
+
+	if manifest.Clang {
+		cmd := exec.Command(getSystemPython(), filepath.Join(installPath, "tools", "idf_tools.py"), "install", "esp-clang")
+		cmd.Dir = installPath
+		cmd.Env = append(os.Environ(), offlineEnv...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("Warning: Failed to install esp-clang: %v\n", err)
+			manifest.Clang = false
+		}
+	}
+
+	if !isValidESPIDFInstall(installPath) {
+		os.RemoveAll(installPath)
+		return fmt.Errorf("installation at %s is not a valid ESP-IDF installation", installPath)
+	}
+
+	if err := writeInstallManifest(installPath, InstallManifest{
+		Version:     manifest.Version,
+		Source:      "bundle",
+		Targets:     manifest.Targets,
+		Clang:       manifest.Clang,
+		InstalledAt: time.Now(),
+	}); err != nil {
+		fmt.Printf("Warning: Could not write install manifest: %v\n", err)
+	}
+
+	fmt.Printf("ESP-IDF version %s installed successfully at %s\n", manifest.Version, installPath)
+	return nil
+}
+
+func getIDFToolsPlatform() (string, error) {
+	platforms := map[string]string{
+		"linux/amd64":   "linux-amd64",
+		"linux/arm64":   "linux-arm64",
+		"linux/arm":     "linux-armhf",
+		"linux/386":     "linux-i686",
+		"darwin/amd64":  "macos",
+		"darwin/arm64":  "macos-arm64",
+		"windows/amd64": "win64",
+		"windows/386":   "win32",
+	}
+
+	platform, ok := platforms[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		return "", fmt.Errorf("unsupported platform %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	return platform, nil
+}
+
+func findPythonEnv(toolsPath, version string) (string, string, error) {
+	major, minor, _, ok := parseIDFVersion(version)
+	if !ok {
+		return "", "", fmt.Errorf("cannot determine the Python environment of version %s", version)
+	}
+
+	prefix := fmt.Sprintf("idf%d.%d_py", major, minor)
+	entries, _ := os.ReadDir(filepath.Join(toolsPath, "python_env"))
+
+	var envs []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			envs = append(envs, entry.Name())
+		}
+	}
+
+	if len(envs) == 0 {
+		return "", "", fmt.Errorf("no Python environment found for %s in %s", version, filepath.Join(toolsPath, "python_env"))
+	}
+
+	sort.Strings(envs)
+	name := envs[len(envs)-1]
+	pythonVersion := strings.TrimSuffix(strings.TrimPrefix(name, prefix), "_env")
+	return filepath.Join(toolsPath, "python_env", name), pythonVersion, nil
+}
+
+func getSystemPythonVersion() string {
+	output, err := exec.Command(getSystemPython(), "-c", "import sys; print('%d.%d' % sys.version_info[:2])").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func getConstraintsFile(toolsPath, version string) string {
+	major, minor, _, _ := parseIDFVersion(version)
+	return filepath.Join(toolsPath, fmt.Sprintf("espidf.constraints.v%d.%d.txt", major, minor))
+}
+
+func downloadBundleTools(idfPath string, targets []string, clang bool) error {
+	fmt.Println("Downloading tool distributions...")
+
+	idfTools := filepath.Join(idfPath, "tools", "idf_tools.py")
+	downloads := [][]string{{"download", "--targets=" + strings.Join(targets, ","), "required"}}
+	if clang {
+		downloads = append(downloads, []string{"download", "esp-clang"})
+	}
+
+	for _, args := range downloads {
+		cmd := exec.Command(getSystemPython(), append([]string{idfTools, "--non-interactive"}, args...)...)
+		cmd.Dir = idfPath
+		cmd.Env = append(os.Environ(), "IDF_PATH="+idfPath)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("idf_tools.py %s failed: %w", strings.Join(args, " "), err)
+		}
+	}
+
+	return nil
+}
+
+func getBundleDistFiles(idfPath, toolsPath, platform string, targets []string, clang bool) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(idfPath, "tools", "tools.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tools.json: %w", err)
+	}
+
+	var toolsFile bundleToolsFile
+	if err := json.Unmarshal(data, &toolsFile); err != nil {
+		return nil, fmt.Errorf("failed to parse tools.json: %w", err)
+	}
+
+	var files []string
+	for _, tool := range toolsFile.Tools {
+		install := tool.Install
+		for _, override := range tool.PlatformOverride {
+			if containsString(override.Platforms, platform) && override.Install != "" {
+				install = override.Install
+			}
+		}
+
+		wanted := install == "always" && supportsAnyTarget(tool.SupportedTargets, targets)
+		if clang && tool.Name == "esp-clang" {
+			wanted = true
+		}
+		if !wanted {
+			continue
+		}
+
+		for _, version := range tool.Versions {
+			var status string
+			json.Unmarshal(version["status"], &status)
+			if status != "recommended" {
+				continue
+			}
+
+			raw, ok := version[platform]
+			if !ok {
+				raw, ok = version["any"]
+			}
+			if !ok {
+				continue
+			}
+
+			var download struct {
+				URL string `json:"url"`
+			}
+			if err := json.Unmarshal(raw, &download); err != nil || download.URL == "" {
+				continue
+			}
+
+			u, err := url.Parse(download.URL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid download URL for %s: %w", tool.Name, err)
+			}
+
+			distFile := filepath.Join(toolsPath, "dist", path.Base(u.Path))
+			if !pathExists(distFile) {
+				return nil, fmt.Errorf("distribution of %s not found: %s", tool.Name, distFile)
+			}
+			files = append(files, distFile)
+		}
+	}
+
+	return files, nil
+}
+
+func supportsAnyTarget(supported, targets []string) bool {
+	if len(supported) == 0 || containsString(supported, "all") {
+		return true
+	}
+	for _, target := range targets {
+		if containsString(supported, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func downloadBundleWheels(idfPath, toolsPath, pythonEnv, wheelsPath string) error {
+	fmt.Println("Downloading Python wheels...")
+
+	requirements := filepath.Join(idfPath, "tools", "requirements", "requirements.core.txt")
+	if !pathExists(requirements) {
+		requirements = filepath.Join(idfPath, "requirements.txt")
+	}
+
+	python := filepath.Join(pythonEnv, "bin", "python")
+	if runtime.GOOS == "windows" {
+		python = filepath.Join(pythonEnv, "Scripts", "python.exe")
+	}
+
+	args := []string{"-m", "pip", "download", "--dest", wheelsPath, "-r", requirements, "pip", "setuptools", "wheel"}
+	if constraints := getConstraintsFile(toolsPath, filepath.Base(idfPath)); pathExists(constraints) {
+		args = append(args, "-c", constraints)
+	}
+
+	cmd := exec.Command(python, args...)
+	cmd.Dir = idfPath
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pip download failed: %w", err)
+	}
+
+	return nil
+}
+
+func addTreeToBundle(tw *tar.Writer, root, prefix string, checksums map[string]string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if bundleNoGit && d.IsDir() && d.Name() == ".git" && prefix == "idf" {
+			return filepath.SkipDir
+		}
+		if rel == "." {
+			return nil
+		}
+
+		name := prefix + "/" + filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			return tw.WriteHeader(header)
+		case info.IsDir():
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name + "/"
+			return tw.WriteHeader(header)
+		case info.Mode().IsRegular():
+			return addFileToBundle(tw, path, name, checksums)
+		}
+
+		return nil
+	})
+}
+
+func addFileToBundle(tw *tar.Writer, path, name string, checksums map[string]string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, hash), file); err != nil {
+		return fmt.Errorf("failed to add %s: %w", path, err)
+	}
+
+	checksums[name] = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+func addBytesToBundle(tw *tar.Writer, name string, data []byte, checksums map[string]string) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	if checksums != nil {
+		sum := sha256.Sum256(data)
+		checksums[name] = hex.EncodeToString(sum[:])
+	}
+	return nil
+}
+
+func openBundle(bundlePath string) (*tar.Reader, func(), error) {
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	return tar.NewReader(gz), func() { gz.Close(); file.Close() }, nil
+}
+
+func readBundleManifest(bundlePath string) (*BundleManifest, error) {
+	tr, closeBundle, err := openBundle(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBundle()
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("bundle has no %s", bundleManifestFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Name != bundleManifestFile || header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		var manifest BundleManifest
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", bundleManifestFile, err)
+		}
+		if filepath.Base(manifest.Version) != manifest.Version || manifest.Version == "." || manifest.Version == ".." {
+			return nil, fmt.Errorf("%s has an invalid version %q", bundleManifestFile, manifest.Version)
+		}
+		return &manifest, nil
+	}
+}
+
+func extractBundle(bundlePath, dest string, files map[string]string) error {
+	tr, closeBundle, err := openBundle(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer closeBundle()
+
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+
+	// Symlinks are created after all other entries so that no entry is written through one.
+	var symlinks []*tar.Header
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %w", err)
+		}
+
+		target, err := bundleEntryPath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			symlinks = append(symlinks, header)
+		case tar.TypeReg:
+			if _, ok := files[header.Name]; !ok && header.Name != bundleManifestFile {
+				return fmt.Errorf("bundle contains %s, which is not listed in %s", header.Name, bundleManifestFile)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, header := range symlinks {
+		target, err := bundleEntryPath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(header.Linkname) || !isWithinDir(dest, filepath.Join(filepath.Dir(target), header.Linkname)) {
+			return fmt.Errorf("bundle contains a symlink pointing outside of it: %s -> %s", header.Name, header.Linkname)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.Symlink(header.Linkname, target); err != nil {
+			return err
+		}
+	}
+
+	// Links through other links (e.g. a -> b/.. with b -> ..) only show up once resolved.
+	for _, header := range symlinks {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(dest, filepath.FromSlash(header.Name)))
+		if err != nil {
+			continue
+		}
+		if !isWithinDir(dest, resolved) {
+			return fmt.Errorf("bundle contains a symlink pointing outside of it: %s -> %s", header.Name, header.Linkname)
+		}
+	}
+	return nil
+}
+
+func bundleEntryPath(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("bundle contains invalid path %s", name)
+	}
+
+	for dir := filepath.Dir(target); dir != dest; dir = filepath.Dir(dir) {
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("bundle contains invalid path %s", name)
+		}
+	}
+	return target, nil
+}
+
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+func writeArchive(output string, write func(tw *tar.Writer) error) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	if err := write(tw); err != nil {
+		return 0, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	if err := gz.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	return info.Size(), nil
+}
+
+func verifyBundleFiles(root string, checksums map[string]string) error {
+	for name, expected := range checksums {
+		actual, err := sha256File(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return fmt.Errorf("bundle is incomplete: %w", err)
+		}
+		if actual != expected {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+	}
+	return nil
+}
+
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func copyBundleDir(src, dest string) error {
+	entries, err := os.ReadDir(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		if err := copyFile(filepath.Join(src, entry.Name()), filepath.Join(dest, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func completeFirstArg(complete cobra.CompletionFunc) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(cmd, args, toComplete)
+	}
+}
+
+func completeInstalledVersions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	versions, err := getInstalledVersions(getESPBase())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []string
+	for _, version := range versions {
+		if !containsString(args, version) {
+			completions = append(completions, version)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeRemovableVersions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if containsString(args, "all") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	completions, directive := completeInstalledVersions(cmd, args, toComplete)
+	if len(args) == 0 {
+		completions = append([]string{"all"}, completions...)
+	}
+	return completions, directive
+}
+
+func completeRemoteVersions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	completions := []string{"latest"}
+
+	releases, err := loadCachedReleases(24 * time.Hour)
+	if err != nil {
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].PublishedAt.After(releases[j].PublishedAt)
+	})
+	for _, release := range releases {
+		completions = append(completions, release.TagName)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func completeTrashedVersions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	entries, err := getTrashEntries()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []string
+	for _, entry := range entries {
+		if !containsString(completions, entry.Version) {
+			completions = append(completions, entry.Version)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeTargets(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return supportedTargets, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeTargetList(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+	}
+	chosen := strings.Split(prefix, ",")
+
+	var completions []string
+	for _, target := range supportedTargets {
+		if !containsString(chosen, target) {
+			completions = append(completions, prefix+target)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func completeVersionList(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+	}
+	chosen := strings.Split(prefix, ",")
+
+	var completions []string
+	if prefix == "" {
+		completions = append(completions, "all-installed")
+	}
+	versions, _ := getInstalledVersions(getESPBase())
+	for _, version := range versions {
+		if !containsString(chosen, version) {
+			completions = append(completions, prefix+version)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func completeSerialPorts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return getSerialPorts(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func getSerialPorts() []string {
+	var patterns []string
+	switch runtime.GOOS {
+	case "linux":
+		patterns = []string{"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/serial/by-id/*"}
+	case "darwin":
+		patterns = []string{"/dev/cu.usbserial*", "/dev/cu.usbmodem*", "/dev/cu.SLAB_USBtoUART*", "/dev/cu.wchusbserial*"}
+	case "windows":
+		return nil
+	default:
+		patterns = []string{"/dev/ttyU*", "/dev/cuaU*"}
+	}
+
+	var ports []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		ports = append(ports, matches...)
+	}
+	return ports
+}
+
+func completeToolchains(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return toolchainNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	manifest, err := getProjectManifest()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return manifestProfileNames(manifest), cobra.ShellCompDirectiveNoFileComp
+}
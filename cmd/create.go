@@ -40,6 +40,8 @@ func init() {
 	createCmd.Flags().BoolVar(&component, "component", false, "Create ESP-IDF registry component")
 	createCmd.Flags().StringVar(&idfVersion, "version", "", "ESP-IDF version to use (default: latest installed)")
 	createCmd.Flags().StringVarP(&target, "target", "t", "esp32", "Target chip (default: esp32)")
+	createCmd.RegisterFlagCompletionFunc("version", completeInstalledVersions)
+	createCmd.RegisterFlagCompletionFunc("target", completeTargets)
 	rootCmd.AddCommand(createCmd)
 }
 
@@ -48,15 +50,14 @@ func createProject(projectName string) error {
         return createComponent(projectName)
     }
 
-	version, _ := getLatestInstalledESPIDFVersion()
-	if idfVersion != "" {
-		if _, err := os.Stat(filepath.Join(getESPBase(), idfVersion)); err == nil {
-			version = idfVersion
+	version, idfPath := idfVersion, filepath.Join(getESPBase(), idfVersion)
+	if _, err := os.Stat(idfPath); idfVersion == "" || err != nil {
+		version, idfPath, err = resolveIDF(getLatestInstalledESPIDFVersion)
+		if err != nil {
+			return err
 		}
 	}
 
-	idfPath := filepath.Join(getESPBase(), version)
-
 	env, err := getESPIDFEnvironment(idfPath)
 	if err != nil {
 		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
@@ -85,6 +86,10 @@ func createProject(projectName string) error {
 		}
 	}
 
+	if err := registerProject(projectPath); err != nil {
+		fmt.Printf("Warning: Could not register project: %v\n", err)
+	}
+
 	fmt.Printf("Project '%s' created successfully!\n", projectName)
 	return nil
 }
@@ -94,6 +99,10 @@ func applyCommonModifications(projectPath, version, idfPath string, env []string
 		return fmt.Errorf("failed to create .espidf-version: %w", err)
 	}
 
+	if err := createProjectManifest(projectPath, version, target); err != nil {
+		return fmt.Errorf("failed to create %s: %w", manifestFile, err)
+	}
+
 	if err := setTarget(projectPath, idfPath, env); err != nil {
 		return fmt.Errorf("failed to set target: %w", err)
 	}
@@ -182,6 +191,10 @@ dependencies: []`,
 		return err
 	}
 
+	if err := registerProject(root); err != nil {
+		fmt.Printf("Warning: Could not register component: %v\n", err)
+	}
+
 	fmt.Printf("Component '%s' created successfully!\n", projectName)
 	return nil
 }
@@ -205,9 +218,12 @@ func setTarget(projectPath, idfPath string, env []string) error {
 func createGitignore(projectPath string) error {
 	gitignoreContent := `.cache/
 build/
-build-clang/
+build-*/
+dist/
 sdkconfig
 sdkconfig.old
+sdkconfig.*
+!sdkconfig.defaults*
 *.bin
 *.elf
 *.map
@@ -216,6 +232,7 @@ dependencies.lock
 sdkconfig
 sdkconfig.old
 warnings.txt
+warnings.json
 `
 	gitignoreFile := filepath.Join(projectPath, ".gitignore")
 	return os.WriteFile(gitignoreFile, []byte(gitignoreContent), 0o644)
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const savedEnvVar = "IDFMGR_OLD_ENV"
+
+var deactivateShell string
+
+type envChange struct {
+	Key     string
+	Value   string
+	Unset   bool
+	Prepend []string
+}
+
+type savedEnvironment struct {
+	Version   string             `json:"version"`
+	Vars      map[string]*string `json:"vars"`
+	PathAdded []string           `json:"path_added"`
+}
+
+var deactivateCmd = &cobra.Command{
+	Use:   "deactivate",
+	Short: "Restore the environment from before 'idfmgr activate'",
+	Long:  `Print shell commands that undo the changes made by 'idfmgr activate'`,
+	Args:  cobra.NoArgs,
+	Example: `  eval "$(idfmgr deactivate)"
+  idfmgr deactivate --shell fish | source`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := deactivateProject(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deactivating ESP-IDF environment: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	deactivateCmd.Flags().StringVar(&deactivateShell, "shell", "", "Shell to print commands for: "+strings.Join(supportedShells, ", ")+" (auto-detected by default)")
+	rootCmd.AddCommand(deactivateCmd)
+}
+
+func deactivateProject() error {
+	current := envListToMap(os.Environ())
+	if _, ok := current[savedEnvVar]; !ok {
+		return fmt.Errorf("no environment activated by idfmgr is active")
+	}
+
+	shell, err := resolveShell(deactivateShell)
+	if err != nil {
+		return err
+	}
+
+	restored, err := restoredEnvironment(current)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(renderShellChanges(shell, diffEnvMaps(current, restored)))
+	return nil
+}
+
+func activatedEnvironment(current map[string]string, delta EnvDelta, version string) (map[string]string, error) {
+	base, err := restoredEnvironment(current)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make(map[string]string, len(base))
+	for key, value := range base {
+		target[key] = value
+	}
+
+	saved := savedEnvironment{Version: version, Vars: make(map[string]*string)}
+	for key, value := range delta.Vars {
+		if base[key] == value {
+			continue
+		}
+		if previous, ok := base[key]; ok {
+			saved.Vars[key] = &previous
+		} else {
+			saved.Vars[key] = nil
+		}
+		target[key] = value
+	}
+
+	existing := make(map[string]bool)
+	for _, entry := range filepath.SplitList(base["PATH"]) {
+		existing[entry] = true
+	}
+	for _, entry := range delta.PathPrepend {
+		if !existing[entry] {
+			saved.PathAdded = append(saved.PathAdded, entry)
+		}
+	}
+	if len(saved.PathAdded) > 0 {
+		target["PATH"] = strings.Join(append(append([]string{}, saved.PathAdded...), filepath.SplitList(base["PATH"])...), string(os.PathListSeparator))
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return nil, err
+	}
+	target[savedEnvVar] = base64.StdEncoding.EncodeToString(data)
+
+	return target, nil
+}
+
+func restoredEnvironment(current map[string]string) (map[string]string, error) {
+	restored := make(map[string]string, len(current))
+	for key, value := range current {
+		restored[key] = value
+	}
+
+	encoded, ok := current[savedEnvVar]
+	if !ok {
+		return restored, nil
+	}
+
+	saved, err := decodeSavedEnvironment(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, previous := range saved.Vars {
+		if previous == nil {
+			delete(restored, key)
+		} else {
+			restored[key] = *previous
+		}
+	}
+
+	added := make(map[string]bool)
+	for _, entry := range saved.PathAdded {
+		added[entry] = true
+	}
+	var path []string
+	for _, entry := range filepath.SplitList(current["PATH"]) {
+		if !added[entry] {
+			path = append(path, entry)
+		}
+	}
+	restored["PATH"] = strings.Join(path, string(os.PathListSeparator))
+	delete(restored, savedEnvVar)
+
+	return restored, nil
+}
+
+func decodeSavedEnvironment(encoded string) (*savedEnvironment, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", savedEnvVar, err)
+	}
+
+	var saved savedEnvironment
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", savedEnvVar, err)
+	}
+	return &saved, nil
+}
+
+func diffEnvMaps(current, target map[string]string) []envChange {
+	keys := make(map[string]bool)
+	for key := range current {
+		keys[key] = true
+	}
+	for key := range target {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []envChange
+	for _, key := range sorted {
+		currentValue, inCurrent := current[key]
+		targetValue, inTarget := target[key]
+
+		switch {
+		case !inTarget && inCurrent:
+			changes = append(changes, envChange{Key: key, Unset: true})
+		case inTarget && (!inCurrent || currentValue != targetValue):
+			change := envChange{Key: key, Value: targetValue}
+			if key == "PATH" && inCurrent && currentValue != "" {
+				suffix := string(os.PathListSeparator) + currentValue
+				if prefix, found := strings.CutSuffix(targetValue, suffix); found {
+					change.Prepend = filepath.SplitList(prefix)
+				}
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
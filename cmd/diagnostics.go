@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	warningsTextFile     = "warnings.txt"
+	warningsJSONFile     = "warnings.json"
+	warningsBaselineFile = "warnings-baseline.json"
+	warningsRecordFile   = "idfmgr-warnings.json"
+)
+
+var (
+	ansiEscapePattern  = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	ninjaObjectPattern = regexp.MustCompile(`^(?:\[\d+/\d+\] Building \S+ object |FAILED: )(\S+\.obj)\b`)
+	diagnosticPattern  = regexp.MustCompile(`^((?:[A-Za-z]:)?[^:\s][^:]*):(\d+):(?:(\d+):)?\s+(warning|error|fatal error):\s+(.*?)(?:\s+\[(-W[^\]]+)\])?$`)
+)
+
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Flag     string `json:"flag,omitempty"`
+	Count    int    `json:"count"`
+}
+
+// diagnosticCollector groups diagnostics by the object file ninja was building when they
+// were printed, so that an incremental build only replaces the results of recompiled files.
+type diagnosticCollector struct {
+	mu         sync.Mutex
+	projectDir string
+	object     string
+	built      map[string]bool
+	byObject   map[string]map[string]*Diagnostic
+	streams    []*diagnosticStream
+}
+
+type warningsRecord struct {
+	Complete bool                    `json:"complete"`
+	Objects  map[string][]Diagnostic `json:"objects"`
+}
+
+type diagnosticStream struct {
+	collector *diagnosticCollector
+	pending   []byte
+}
+
+func newDiagnosticCollector(projectDir string) *diagnosticCollector {
+	if abs, err := filepath.Abs(projectDir); err == nil {
+		projectDir = abs
+	}
+	return &diagnosticCollector{
+		projectDir: projectDir,
+		built:      make(map[string]bool),
+		byObject:   make(map[string]map[string]*Diagnostic),
+	}
+}
+
+func (c *diagnosticCollector) stream() io.Writer {
+	s := &diagnosticStream{collector: c}
+	c.streams = append(c.streams, s)
+	return s
+}
+
+func (c *diagnosticCollector) flush() {
+	for _, s := range c.streams {
+		if len(s.pending) > 0 {
+			c.addLine(string(s.pending))
+			s.pending = nil
+		}
+	}
+}
+
+func (s *diagnosticStream) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	for {
+		i := bytes.IndexByte(s.pending, '\n')
+		if i < 0 {
+			break
+		}
+		s.collector.addLine(string(s.pending[:i]))
+		s.pending = s.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+func (c *diagnosticCollector) addLine(line string) {
+	line = strings.TrimRight(ansiEscapePattern.ReplaceAllString(line, ""), "\r")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if match := ninjaObjectPattern.FindStringSubmatch(line); match != nil {
+		c.object = filepath.ToSlash(match[1])
+		c.built[c.object] = true
+		return
+	}
+
+	diagnostic, ok := parseDiagnostic(line)
+	if !ok {
+		return
+	}
+	diagnostic.File = c.relativePath(diagnostic.File)
+
+	diagnostics := c.byObject[c.object]
+	if diagnostics == nil {
+		diagnostics = make(map[string]*Diagnostic)
+		c.byObject[c.object] = diagnostics
+	}
+	key := diagnostic.key()
+	if existing, ok := diagnostics[key]; ok {
+		existing.Count++
+		return
+	}
+	diagnostic.Count = 1
+	diagnostics[key] = &diagnostic
+}
+
+func parseDiagnostic(line string) (Diagnostic, bool) {
+	match := diagnosticPattern.FindStringSubmatch(line)
+	if match == nil {
+		return Diagnostic{}, false
+	}
+
+	diagnostic := Diagnostic{
+		File:     match[1],
+		Severity: match[4],
+		Message:  match[5],
+		Flag:     normalizeWarningFlag(match[6]),
+	}
+	diagnostic.Line, _ = strconv.Atoi(match[2])
+	diagnostic.Column, _ = strconv.Atoi(match[3])
+	if diagnostic.Severity == "fatal error" {
+		diagnostic.Severity = "error"
+	}
+	return diagnostic, true
+}
+
+func (d Diagnostic) key() string {
+	return fmt.Sprintf("%s:%d:%d:%s:%s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+func (c *diagnosticCollector) relativePath(file string) string {
+	if !filepath.IsAbs(file) {
+		return filepath.ToSlash(file)
+	}
+	if rel, err := filepath.Rel(c.projectDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(file)
+}
+
+func normalizeWarningFlag(flag string) string {
+	if i := strings.LastIndex(flag, ",-W"); i >= 0 {
+		flag = flag[i+1:]
+	}
+	if name, found := strings.CutPrefix(flag, "-Werror="); found {
+		flag = "-W" + name
+	}
+	return flag
+}
+
+// merge combines the diagnostics of this build with those recorded in buildDir for the
+// object files that were not rebuilt, and records the result. complete is false while the
+// record started from a build directory that was already built without one.
+func (c *diagnosticCollector) merge(buildDir string, hadBuild bool) (diagnostics []Diagnostic, complete bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	record := readWarningsRecord(buildDir)
+	if record == nil {
+		record = &warningsRecord{Complete: !hadBuild, Objects: make(map[string][]Diagnostic)}
+	}
+
+	delete(record.Objects, "")
+	for object := range record.Objects {
+		if c.built[object] || !pathExists(filepath.Join(buildDir, filepath.FromSlash(object))) {
+			delete(record.Objects, object)
+		}
+	}
+	for object, objectDiagnostics := range c.byObject {
+		list := make([]Diagnostic, 0, len(objectDiagnostics))
+		for _, diagnostic := range objectDiagnostics {
+			list = append(list, *diagnostic)
+		}
+		record.Objects[object] = list
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, false, err
+	}
+	if err := writeFileAtomic(filepath.Join(buildDir, warningsRecordFile), data, 0o644); err != nil {
+		return nil, false, err
+	}
+
+	merged := make(map[string]*Diagnostic)
+	for _, objectDiagnostics := range record.Objects {
+		for _, diagnostic := range objectDiagnostics {
+			if existing, ok := merged[diagnostic.key()]; ok {
+				existing.Count += diagnostic.Count
+				continue
+			}
+			diagnostic := diagnostic
+			merged[diagnostic.key()] = &diagnostic
+		}
+	}
+	for _, diagnostic := range merged {
+		diagnostics = append(diagnostics, *diagnostic)
+	}
+	sortDiagnostics(diagnostics)
+	return diagnostics, record.Complete, nil
+}
+
+func readWarningsRecord(buildDir string) *warningsRecord {
+	data, err := os.ReadFile(filepath.Join(buildDir, warningsRecordFile))
+	if err != nil {
+		return nil
+	}
+	var record warningsRecord
+	if json.Unmarshal(data, &record) != nil || record.Objects == nil {
+		return nil
+	}
+	return &record
+}
+
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.Slice(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Severity != b.Severity {
+			return a.Severity == "error"
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Message < b.Message
+	})
+}
+
+func (d Diagnostic) String() string {
+	location := fmt.Sprintf("%s:%d", d.File, d.Line)
+	if d.Column > 0 {
+		location += fmt.Sprintf(":%d", d.Column)
+	}
+	line := fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+	if d.Flag != "" {
+		line += fmt.Sprintf(" [%s]", d.Flag)
+	}
+	return line
+}
+
+func (d Diagnostic) baselineKey() string {
+	return strings.Join([]string{d.File, d.Severity, d.Flag, d.Message}, "\x00")
+}
+
+func writeDiagnostics(projectDir string, diagnostics []Diagnostic) error {
+	var text strings.Builder
+	for _, diagnostic := range diagnostics {
+		text.WriteString(diagnostic.String() + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, warningsTextFile), []byte(text.String()), 0o644); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(diagnostics, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(projectDir, warningsJSONFile), append(data, '\n'), 0o644)
+}
+
+func loadWarningsBaseline(path string) ([]Diagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline []Diagnostic
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid warnings baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+func newWarnings(diagnostics, baseline []Diagnostic) []Diagnostic {
+	known := make(map[string]bool)
+	for _, diagnostic := range baseline {
+		known[diagnostic.baselineKey()] = true
+	}
+
+	var added []Diagnostic
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == "warning" && !known[diagnostic.baselineKey()] {
+			added = append(added, diagnostic)
+		}
+	}
+	return added
+}
+
+func reportDiagnostics(collector *diagnosticCollector, projectDir, buildDir string, hadBuild, werrorNew, saveBaseline bool) error {
+	collector.flush()
+	diagnostics, complete, err := collector.merge(buildDir, hadBuild)
+	if err != nil {
+		return fmt.Errorf("failed to record diagnostics: %w", err)
+	}
+	if err := writeDiagnostics(projectDir, diagnostics); err != nil {
+		fmt.Printf("Warning: Could not write %s: %v\n", warningsTextFile, err)
+	}
+	printDiagnosticsSummary(diagnostics, 20)
+	if !complete {
+		fmt.Printf("Note: %s only lists the files compiled in this build. Files built earlier are included once they are recompiled (e.g. after idf.py fullclean).\n", warningsTextFile)
+	}
+
+	baselinePath := filepath.Join(projectDir, warningsBaselineFile)
+	if saveBaseline {
+		data, err := json.MarshalIndent(diagnostics, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(baselinePath, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("failed to save warnings baseline: %w", err)
+		}
+		fmt.Printf("Saved %d diagnostic(s) to %s\n", len(diagnostics), warningsBaselineFile)
+		return nil
+	}
+	if !werrorNew {
+		return nil
+	}
+
+	baseline, err := loadWarningsBaseline(baselinePath)
+	if os.IsNotExist(err) {
+		fmt.Printf("No %s found, every warning counts as new. Create it with: idfmgr build --save-warnings-baseline\n", warningsBaselineFile)
+	} else if err != nil {
+		return err
+	}
+
+	added := newWarnings(diagnostics, baseline)
+	if len(added) == 0 {
+		return nil
+	}
+	fmt.Printf("\nNew warnings (not in %s):\n", warningsBaselineFile)
+	for _, diagnostic := range added {
+		fmt.Printf("  %s\n", diagnostic)
+	}
+	return fmt.Errorf("%d new warning(s)", len(added))
+}
+
+// hasWarningsRecord reports whether buildDir can be built incrementally without losing the
+// diagnostics of files that are not recompiled.
+func hasWarningsRecord(buildDir string) bool {
+	if record := readWarningsRecord(buildDir); record != nil {
+		return record.Complete
+	}
+	return !pathExists(filepath.Join(buildDir, "build.ninja"))
+}
+
+func printDiagnosticsSummary(diagnostics []Diagnostic, limit int) {
+	if len(diagnostics) == 0 {
+		return
+	}
+
+	errors, warnings := 0, 0
+	flags := make(map[string]int)
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == "error" {
+			errors++
+		} else {
+			warnings++
+			if diagnostic.Flag != "" {
+				flags[diagnostic.Flag]++
+			}
+		}
+	}
+
+	fmt.Println("\nDiagnostics")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("%d error(s), %d warning(s)\n", errors, warnings)
+	for i, diagnostic := range diagnostics {
+		if i == limit {
+			fmt.Printf("... and %d more, see %s\n", len(diagnostics)-limit, warningsTextFile)
+			break
+		}
+		fmt.Println(diagnostic.String())
+	}
+
+	if len(flags) > 0 {
+		names := make([]string, 0, len(flags))
+		for flag := range flags {
+			names = append(names, flag)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if flags[names[i]] != flags[names[j]] {
+				return flags[names[i]] > flags[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		var parts []string
+		for _, flag := range names {
+			parts = append(parts, fmt.Sprintf("%s (%d)", flag, flags[flag]))
+		}
+		fmt.Printf("Warning flags: %s\n", strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostic(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Diagnostic
+		ok   bool
+	}{
+		{
+			name: "gcc warning",
+			line: "main/main.c:12:9: warning: unused variable 'count' [-Wunused-variable]",
+			want: Diagnostic{File: "main/main.c", Line: 12, Column: 9, Severity: "warning", Message: "unused variable 'count'", Flag: "-Wunused-variable"},
+			ok:   true,
+		},
+		{
+			name: "gcc warning promoted by -Werror",
+			line: "main/wifi.c:88:5: error: implicit declaration of function 'delay_ms' [-Werror=implicit-function-declaration]",
+			want: Diagnostic{File: "main/wifi.c", Line: 88, Column: 5, Severity: "error", Message: "implicit declaration of function 'delay_ms'", Flag: "-Wimplicit-function-declaration"},
+			ok:   true,
+		},
+		{
+			name: "clang warning promoted by -Werror",
+			line: "main/main.c:4:7: error: unused variable 'x' [-Werror,-Wunused-variable]",
+			want: Diagnostic{File: "main/main.c", Line: 4, Column: 7, Severity: "error", Message: "unused variable 'x'", Flag: "-Wunused-variable"},
+			ok:   true,
+		},
+		{
+			name: "fatal error without flag",
+			line: "main/main.c:1:10: fatal error: missing.h: No such file or directory",
+			want: Diagnostic{File: "main/main.c", Line: 1, Column: 10, Severity: "error", Message: "missing.h: No such file or directory"},
+			ok:   true,
+		},
+		{
+			name: "no column",
+			line: "/opt/esp/idf/components/log/include/esp_log.h:3: warning: \"LOG_LOCAL_LEVEL\" redefined",
+			want: Diagnostic{File: "/opt/esp/idf/components/log/include/esp_log.h", Line: 3, Severity: "warning", Message: "\"LOG_LOCAL_LEVEL\" redefined"},
+			ok:   true,
+		},
+		{
+			name: "windows path",
+			line: `C:\esp\blink\main\main.c:3:5: warning: unused parameter 'arg' [-Wunused-parameter]`,
+			want: Diagnostic{File: `C:\esp\blink\main\main.c`, Line: 3, Column: 5, Severity: "warning", Message: "unused parameter 'arg'", Flag: "-Wunused-parameter"},
+			ok:   true,
+		},
+		{
+			name: "note",
+			line: "main/main.c:12:9: note: declared here",
+		},
+		{
+			name: "ninja status",
+			line: "[12/40] Building C object esp-idf/main/CMakeFiles/__idf_main.dir/main.c.obj",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseDiagnostic(tt.line)
+			if ok != tt.ok {
+				t.Fatalf("parseDiagnostic(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseDiagnostic(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeWarningFlag(t *testing.T) {
+	tests := map[string]string{
+		"":                                      "",
+		"-Wunused-variable":                     "-Wunused-variable",
+		"-Werror=unused-variable":               "-Wunused-variable",
+		"-Werror,-Wunused-variable":             "-Wunused-variable",
+		"-Wdeprecated-declarations":             "-Wdeprecated-declarations",
+		"-Werror=format-truncation=":            "-Wformat-truncation=",
+		"-Werror,-Wimplicit-fallthrough":        "-Wimplicit-fallthrough",
+		"-Werror=implicit-function-declaration": "-Wimplicit-function-declaration",
+	}
+	for flag, want := range tests {
+		if got := normalizeWarningFlag(flag); got != want {
+			t.Errorf("normalizeWarningFlag(%q) = %q, want %q", flag, got, want)
+		}
+	}
+}
+
+func TestDiagnosticCollector(t *testing.T) {
+	projectDir := t.TempDir()
+	collector := newDiagnosticCollector(projectDir)
+	stdout, stderr := collector.stream(), collector.stream()
+
+	fmt.Fprint(stdout, "[1/3] Building C object esp-idf/main/CMakeFiles/__idf_main.dir/main.c.obj\n")
+	fmt.Fprintf(stdout, "\x1b[01m\x1b[K%s/main/main.c:12:9:\x1b[m\x1b[K \x1b[01;35m\x1b[Kwarning: \x1b[m\x1b[Kunused variable 'count' [\x1b[01;35m\x1b[K-Wunused-variable\x1b[m\x1b[K]\n", projectDir)
+	fmt.Fprint(stdout, "main/main.c:12:9: warning: unused variable 'count' [-Wunused-variable]\r\n")
+	fmt.Fprint(stdout, "[2/3] Building C object esp-idf/main/CMakeFiles/__idf_main.dir/wifi.c.obj\n")
+	fmt.Fprint(stderr, "main/wifi.c:88:5: warning: implicit declaration of function 'delay_ms' ")
+	fmt.Fprint(stderr, "[-Wimplicit-function-declaration]")
+	collector.flush()
+
+	main := collector.byObject["esp-idf/main/CMakeFiles/__idf_main.dir/main.c.obj"]
+	if len(main) != 1 {
+		t.Fatalf("main.c.obj has %d diagnostics, want 1", len(main))
+	}
+	for _, diagnostic := range main {
+		if diagnostic.File != "main/main.c" || diagnostic.Count != 2 {
+			t.Errorf("main.c.obj diagnostic = %+v, want main/main.c seen twice", diagnostic)
+		}
+	}
+	if len(collector.byObject["esp-idf/main/CMakeFiles/__idf_main.dir/wifi.c.obj"]) != 1 {
+		t.Errorf("the last line without a newline was not collected")
+	}
+}
+
+func TestDiagnosticCollectorMerge(t *testing.T) {
+	projectDir := t.TempDir()
+	buildDir := filepath.Join(projectDir, "build")
+	objects := []string{"main.c.obj", "wifi.c.obj", "removed.c.obj"}
+	for _, object := range objects[:2] {
+		if err := os.MkdirAll(buildDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(buildDir, object), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	full := newDiagnosticCollector(projectDir)
+	for _, line := range []string{
+		"[1/3] Building C object main.c.obj",
+		"main/main.c:1:1: warning: old main warning [-Wunused-variable]",
+		"[2/3] Building C object wifi.c.obj",
+		"main/wifi.c:2:2: warning: wifi warning [-Wunused-variable]",
+		"[3/3] Building C object removed.c.obj",
+		"main/removed.c:3:3: warning: removed warning [-Wunused-variable]",
+	} {
+		full.addLine(line)
+	}
+	diagnostics, complete, err := full.merge(buildDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !complete || len(diagnostics) != 3 {
+		t.Fatalf("full build: %d diagnostics (complete %v), want 3 (complete true)", len(diagnostics), complete)
+	}
+
+	incremental := newDiagnosticCollector(projectDir)
+	incremental.addLine("[1/1] Building C object main.c.obj")
+	diagnostics, complete, err = incremental.merge(buildDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for _, diagnostic := range diagnostics {
+		files = append(files, diagnostic.File)
+	}
+	if !complete || !reflect.DeepEqual(files, []string{"main/wifi.c"}) {
+		t.Errorf("incremental build: files %v (complete %v), want [main/wifi.c] (complete true)", files, complete)
+	}
+
+	legacyDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(legacyDir, "build.ninja"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, complete, _ := newDiagnosticCollector(projectDir).merge(legacyDir, true); complete {
+			t.Errorf("build %d: a record started from an earlier build should stay incomplete", i+1)
+		}
+	}
+	if hasWarningsRecord(legacyDir) {
+		t.Errorf("hasWarningsRecord() = true for an incomplete record")
+	}
+}
+
+func TestNewWarnings(t *testing.T) {
+	baseline := []Diagnostic{
+		{File: "main/main.c", Line: 12, Column: 9, Severity: "warning", Message: "unused variable 'count'", Flag: "-Wunused-variable"},
+	}
+	diagnostics := []Diagnostic{
+		{File: "main/main.c", Line: 30, Column: 9, Severity: "warning", Message: "unused variable 'count'", Flag: "-Wunused-variable"},
+		{File: "main/main.c", Line: 31, Column: 9, Severity: "warning", Message: "unused variable 'total'", Flag: "-Wunused-variable"},
+		{File: "main/wifi.c", Line: 88, Column: 5, Severity: "error", Message: "implicit declaration of function 'delay_ms'"},
+	}
+
+	added := newWarnings(diagnostics, baseline)
+	if len(added) != 1 || added[0].Message != "unused variable 'total'" {
+		t.Errorf("newWarnings() = %+v, want only the 'total' warning", added)
+	}
+	if added := newWarnings(diagnostics, nil); len(added) != 2 {
+		t.Errorf("newWarnings() without baseline returned %d warnings, want 2", len(added))
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var direnvForce bool
+
+const direnvContent = `# Generated by idfmgr. Loads the ESP-IDF environment pinned in .espidf-version or idfmgr.toml.
+watch_file .espidf-version
+watch_file idfmgr.toml
+eval "$(idfmgr activate --shell bash)"
+`
+
+var direnvCmd = &cobra.Command{
+	Use:   "direnv",
+	Short: "Write an .envrc that loads the project's ESP-IDF environment",
+	Long: `Write an .envrc for direnv in the current project. It loads the same cached environment
+as 'idfmgr activate', and reloads it when .espidf-version or idfmgr.toml change.`,
+	Args: cobra.NoArgs,
+	Example: `  idfmgr direnv
+  direnv allow`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := writeDirenvFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing .envrc: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	direnvCmd.Flags().BoolVarP(&direnvForce, "force", "f", false, "Overwrite an existing .envrc")
+	rootCmd.AddCommand(direnvCmd)
+}
+
+func writeDirenvFile() error {
+	root, err := getProjectRoot()
+	if err != nil {
+		return err
+	}
+
+	envrc := filepath.Join(root, ".envrc")
+	if existing, err := os.ReadFile(envrc); err == nil {
+		if strings.Contains(string(existing), "idfmgr activate") {
+			fmt.Println(".envrc already loads the ESP-IDF environment.")
+			return nil
+		}
+		if !direnvForce {
+			return fmt.Errorf(".envrc already exists, use --force to overwrite it")
+		}
+	}
+
+	if err := os.WriteFile(envrc, []byte(direnvContent), 0o644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created %s\n", envrc)
+	if commandExists("direnv") {
+		fmt.Println("Run 'direnv allow' to enable it.")
+	} else {
+		fmt.Println("direnv was not found in PATH, install it from https://direnv.net and run 'direnv allow'.")
+	}
+	return nil
+}
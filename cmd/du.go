@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type idfToolsFile struct {
+	Tools []struct {
+		Name     string `json:"name"`
+		Versions []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"versions"`
+	} `json:"tools"`
+}
+
+type toolUsage struct {
+	Name      string
+	Version   string
+	Path      string
+	Toolchain bool
+	Size      int64
+	Users     []string
+}
+
+type versionUsage struct {
+	Version    string
+	Git        int64
+	Source     int64
+	Submodules int64
+	PythonEnv  int64
+	Toolchains int64
+	OtherTools int64
+}
+
+func (u versionUsage) Total() int64 {
+	return u.Git + u.Source + u.Submodules + u.PythonEnv + u.Toolchains + u.OtherTools
+}
+
+var duCmd = &cobra.Command{
+	Use:   "du [version...]",
+	Short: "Show disk usage of installed ESP-IDF versions",
+	Long: `Break down the disk usage of each installed ESP-IDF version into git objects,
+source, submodules, Python environment, toolchains and other tools. Tools shared
+between versions and space that no installed version uses are listed separately.`,
+	Example: `  idfmgr du
+  idfmgr du v5.1.2 v5.3`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := showDiskUsage(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error calculating disk usage: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	ValidArgsFunction: completeInstalledVersions,
+}
+
+func init() {
+	rootCmd.AddCommand(duCmd)
+}
+
+func showDiskUsage(selected []string) error {
+	espBase := getESPBase()
+	if _, err := os.Stat(espBase); os.IsNotExist(err) {
+		fmt.Printf("ESP_BASE directory doesn't exist: %s\n", espBase)
+		return nil
+	}
+
+	installed, err := getInstalledVersions(espBase)
+	if err != nil {
+		return fmt.Errorf("failed to get installed versions: %w", err)
+	}
+	sort.Strings(installed)
+
+	versions := installed
+	if len(selected) > 0 {
+		versions = nil
+		for _, version := range selected {
+			if !isValidESPIDFInstall(filepath.Join(espBase, version)) {
+				fmt.Printf("Warning: Version %s is not installed, skipping\n", version)
+				continue
+			}
+			versions = append(versions, version)
+		}
+	}
+
+	if len(versions) == 0 {
+		fmt.Println("No ESP-IDF versions installed.")
+		return nil
+	}
+
+	toolsPath := getIDFToolsPath()
+	tools := collectToolUsage(espBase, installed, toolsPath)
+	pythonEnvs := collectPythonEnvUsage(installed, toolsPath)
+
+	fmt.Printf("Disk usage of ESP-IDF versions in %s:\n\n", espBase)
+	fmt.Printf("%-15s %10s %10s %11s %11s %11s %11s %10s\n",
+		"VERSION", "GIT", "SOURCE", "SUBMODULES", "PYTHON ENV", "TOOLCHAINS", "OTHER TOOLS", "TOTAL")
+	fmt.Printf("%s\n", strings.Repeat("-", 95))
+
+	for _, version := range versions {
+		usage, err := getVersionUsage(espBase, version, tools, pythonEnvs)
+		if err != nil {
+			fmt.Printf("Warning: Could not calculate disk usage of %s: %v\n", version, err)
+			continue
+		}
+
+		fmt.Printf("%-15s %10s %10s %11s %11s %11s %11s %10s\n",
+			usage.Version,
+			formatBytes(usage.Git),
+			formatBytes(usage.Source),
+			formatBytes(usage.Submodules),
+			formatBytes(usage.PythonEnv),
+			formatBytes(usage.Toolchains),
+			formatBytes(usage.OtherTools),
+			formatBytes(usage.Total()),
+		)
+	}
+
+	var shared []*toolUsage
+	for _, tool := range tools {
+		if len(tool.Users) > 1 {
+			shared = append(shared, tool)
+		}
+	}
+	for _, env := range pythonEnvs {
+		if len(env.Users) > 1 {
+			shared = append(shared, env)
+		}
+	}
+
+	sort.Slice(shared, func(i, j int) bool {
+		return shared[i].Path < shared[j].Path
+	})
+
+	if len(shared) > 0 {
+		fmt.Printf("\nShared tools (counted once per version above):\n")
+		for _, tool := range shared {
+			fmt.Printf("  %-40s %10s  used by %s\n",
+				tool.Name+" "+tool.Version, formatBytes(tool.Size), strings.Join(tool.Users, ", "))
+		}
+	}
+
+	reclaimable, err := collectReclaimable(toolsPath, tools, pythonEnvs)
+	if err != nil {
+		fmt.Printf("\nWarning: Could not calculate reclaimable space: %v\n", err)
+		return nil
+	}
+
+	if trashPath := getTrashPath(); pathExists(trashPath) {
+		if size, err := getDirSize(trashPath); err == nil && size > 0 {
+			reclaimable = append(reclaimable, &toolUsage{Name: "trash", Path: trashPath, Size: size})
+		}
+	}
+
+	if len(reclaimable) > 0 {
+		var total int64
+		fmt.Printf("\nReclaimable (not used by any installed version):\n")
+		for _, item := range reclaimable {
+			fmt.Printf("  %-40s %10s  %s\n", item.Name+" "+item.Version, formatBytes(item.Size), item.Path)
+			total += item.Size
+		}
+		fmt.Printf("\nTotal reclaimable: %s\n", formatBytes(total))
+	}
+
+	return nil
+}
+
+func getVersionUsage(espBase, version string, tools map[string]*toolUsage, pythonEnvs map[string]*toolUsage) (versionUsage, error) {
+	usage := versionUsage{Version: version}
+	idfPath := filepath.Join(espBase, version)
+
+	total, err := getDirSize(idfPath)
+	if err != nil {
+		return usage, err
+	}
+
+	if gitDir := filepath.Join(idfPath, ".git"); pathExists(gitDir) {
+		if usage.Git, err = getDirSize(gitDir); err != nil {
+			return usage, err
+		}
+	}
+
+	for _, submodule := range readSubmodulePaths(idfPath) {
+		submodulePath := filepath.Join(idfPath, submodule)
+		if !pathExists(submodulePath) {
+			continue
+		}
+		size, err := getDirSize(submodulePath)
+		if err != nil {
+			return usage, err
+		}
+		usage.Submodules += size
+	}
+
+	usage.Source = total - usage.Git - usage.Submodules
+	if usage.Source < 0 {
+		usage.Source = 0
+	}
+
+	for _, tool := range tools {
+		if !containsString(tool.Users, version) {
+			continue
+		}
+		if tool.Toolchain {
+			usage.Toolchains += tool.Size
+		} else {
+			usage.OtherTools += tool.Size
+		}
+	}
+
+	for _, env := range pythonEnvs {
+		if containsString(env.Users, version) {
+			usage.PythonEnv += env.Size
+		}
+	}
+
+	return usage, nil
+}
+
+func collectToolUsage(espBase string, versions []string, toolsPath string) map[string]*toolUsage {
+	tools := make(map[string]*toolUsage)
+
+	for _, version := range versions {
+		toolsFile, err := readIDFToolsFile(filepath.Join(espBase, version))
+		if err != nil {
+			continue
+		}
+
+		for _, tool := range toolsFile.Tools {
+			for _, toolVersion := range tool.Versions {
+				if toolVersion.Status != "recommended" {
+					continue
+				}
+
+				path := filepath.Join(toolsPath, "tools", tool.Name, toolVersion.Name)
+				if !pathExists(path) {
+					continue
+				}
+
+				usage, ok := tools[path]
+				if !ok {
+					size, err := getDirSize(path)
+					if err != nil {
+						continue
+					}
+					usage = &toolUsage{
+						Name:      tool.Name,
+						Version:   toolVersion.Name,
+						Path:      path,
+						Toolchain: isToolchain(tool.Name),
+						Size:      size,
+					}
+					tools[path] = usage
+				}
+				usage.Users = append(usage.Users, version)
+			}
+		}
+	}
+
+	return tools
+}
+
+func collectPythonEnvUsage(versions []string, toolsPath string) map[string]*toolUsage {
+	envs := make(map[string]*toolUsage)
+
+	entries, err := os.ReadDir(filepath.Join(toolsPath, "python_env"))
+	if err != nil {
+		return envs
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		for _, version := range versions {
+			major, minor, _, ok := parseIDFVersion(version)
+			if !ok || !strings.HasPrefix(entry.Name(), fmt.Sprintf("idf%d.%d_", major, minor)) {
+				continue
+			}
+
+			path := filepath.Join(toolsPath, "python_env", entry.Name())
+			usage, ok := envs[path]
+			if !ok {
+				size, err := getDirSize(path)
+				if err != nil {
+					break
+				}
+				usage = &toolUsage{Name: "python_env", Version: entry.Name(), Path: path, Size: size}
+				envs[path] = usage
+			}
+			usage.Users = append(usage.Users, version)
+		}
+	}
+
+	return envs
+}
+
+func collectReclaimable(toolsPath string, tools, pythonEnvs map[string]*toolUsage) ([]*toolUsage, error) {
+	var reclaimable []*toolUsage
+
+	toolDirs, err := os.ReadDir(filepath.Join(toolsPath, "tools"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, toolDir := range toolDirs {
+		if !toolDir.IsDir() {
+			continue
+		}
+
+		versionDirs, err := os.ReadDir(filepath.Join(toolsPath, "tools", toolDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, versionDir := range versionDirs {
+			path := filepath.Join(toolsPath, "tools", toolDir.Name(), versionDir.Name())
+			if !versionDir.IsDir() || tools[path] != nil {
+				continue
+			}
+
+			size, err := getDirSize(path)
+			if err != nil {
+				return nil, err
+			}
+			reclaimable = append(reclaimable, &toolUsage{Name: toolDir.Name(), Version: versionDir.Name(), Path: path, Size: size})
+		}
+	}
+
+	envDirs, err := os.ReadDir(filepath.Join(toolsPath, "python_env"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, envDir := range envDirs {
+		path := filepath.Join(toolsPath, "python_env", envDir.Name())
+		if !envDir.IsDir() || pythonEnvs[path] != nil {
+			continue
+		}
+
+		size, err := getDirSize(path)
+		if err != nil {
+			return nil, err
+		}
+		reclaimable = append(reclaimable, &toolUsage{Name: "python_env", Version: envDir.Name(), Path: path, Size: size})
+	}
+
+	if distPath := filepath.Join(toolsPath, "dist"); pathExists(distPath) {
+		size, err := getDirSize(distPath)
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 {
+			reclaimable = append(reclaimable, &toolUsage{Name: "download cache", Path: distPath, Size: size})
+		}
+	}
+
+	sort.Slice(reclaimable, func(i, j int) bool {
+		return reclaimable[i].Size > reclaimable[j].Size
+	})
+
+	return reclaimable, nil
+}
+
+func readIDFToolsFile(idfPath string) (*idfToolsFile, error) {
+	data, err := os.ReadFile(filepath.Join(idfPath, "tools", "tools.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var toolsFile idfToolsFile
+	if err := json.Unmarshal(data, &toolsFile); err != nil {
+		return nil, fmt.Errorf("failed to parse tools.json: %w", err)
+	}
+
+	return &toolsFile, nil
+}
+
+func readSubmodulePaths(idfPath string) []string {
+	file, err := os.Open(filepath.Join(idfPath, ".gitmodules"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, found := strings.Cut(line, "=")
+		if found && strings.TrimSpace(key) == "path" {
+			paths = append(paths, filepath.FromSlash(strings.TrimSpace(value)))
+		}
+	}
+
+	return paths
+}
+
+func isToolchain(name string) bool {
+	return strings.HasSuffix(name, "-elf") || name == "esp-clang"
+}
@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type EnvDelta struct {
+	Vars        map[string]string `json:"vars"`
+	PathPrepend []string          `json:"path_prepend"`
+}
+
+type envCacheEntry struct {
+	IDFPath     string            `json:"idf_path"`
+	Fingerprint map[string]string `json:"fingerprint"`
+	Delta       EnvDelta          `json:"delta"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage the cached ESP-IDF environments",
+	Long: `idfmgr caches the environment computed from each ESP-IDF installation so wrapper
+commands start instantly. The cache is invalidated automatically when export.sh, the
+tools JSON or the Python environment change.`,
+}
+
+var envRefreshCmd = &cobra.Command{
+	Use:   "refresh [version...]",
+	Short: "Recompute the cached environment of installed versions",
+	Long:  `Recompute the cached environment of the given versions, or of all installed versions`,
+	Example: `  idfmgr env refresh
+  idfmgr env refresh v5.1.2`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := refreshEnvironments(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error refreshing environment: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	ValidArgsFunction: completeInstalledVersions,
+}
+
+func init() {
+	envCmd.AddCommand(envRefreshCmd)
+	rootCmd.AddCommand(envCmd)
+}
+
+func refreshEnvironments(versions []string) error {
+	espBase := getESPBase()
+	if len(versions) == 0 {
+		installed, err := getInstalledVersions(espBase)
+		if err != nil {
+			return fmt.Errorf("failed to get installed versions: %w", err)
+		}
+		versions = installed
+	}
+
+	if len(versions) == 0 {
+		fmt.Println("No ESP-IDF versions installed.")
+		return nil
+	}
+
+	var failed int
+	for _, version := range versions {
+		idfPath := filepath.Join(espBase, version)
+		if !isValidESPIDFInstall(idfPath) {
+			fmt.Printf("Warning: Version %s is not installed, skipping\n", version)
+			continue
+		}
+
+		fmt.Printf("Refreshing environment of %s...\n", version)
+		if _, err := refreshEnvDelta(idfPath); err != nil {
+			fmt.Printf("Failed to refresh %s: %v\n", version, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d version(s) failed", failed)
+	}
+	return nil
+}
+
+func loadEnvDelta(idfPath string) (EnvDelta, error) {
+	if entry, err := readEnvCache(idfPath); err == nil {
+		if fingerprintsEqual(entry.Fingerprint, computeEnvFingerprint(idfPath, entry.Delta)) {
+			return entry.Delta, nil
+		}
+	}
+
+	return refreshEnvDelta(idfPath)
+}
+
+func refreshEnvDelta(idfPath string) (EnvDelta, error) {
+	delta, err := computeEnvDelta(idfPath)
+	if err != nil {
+		return EnvDelta{}, err
+	}
+
+	entry := envCacheEntry{
+		IDFPath:     idfPath,
+		Fingerprint: computeEnvFingerprint(idfPath, delta),
+		Delta:       delta,
+		CreatedAt:   time.Now(),
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		if err := writeFileAtomic(getEnvCachePath(idfPath), data, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not cache ESP-IDF environment: %v\n", err)
+		}
+	}
+
+	return delta, nil
+}
+
+func computeEnvDelta(idfPath string) (EnvDelta, error) {
+	baseEnv := getBaseEnvironment()
+
+	exported := make(map[string]string, len(baseEnv)+1)
+	for key, value := range baseEnv {
+		exported[key] = value
+	}
+	exported["IDF_PATH"] = idfPath
+
+	var extraPaths []string
+	for _, dir := range []string{
+		filepath.Join(idfPath, "components", "espcoredump"),
+		filepath.Join(idfPath, "components", "partition_table"),
+		filepath.Join(idfPath, "components", "app_update"),
+	} {
+		if pathExists(dir) {
+			extraPaths = append(extraPaths, dir)
+		}
+	}
+
+	idfTools := filepath.Join(idfPath, "tools", "idf_tools.py")
+	cmd := exec.Command(getSystemPython(), idfTools, "export", "--format", "key-value")
+	cmd.Env = envMapToList(exported)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return EnvDelta{}, fmt.Errorf("idf_tools.py export failed: %s", message)
+		}
+		return EnvDelta{}, fmt.Errorf("idf_tools.py export failed: %w", err)
+	}
+
+	var exportedPath []string
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, found := strings.Cut(strings.TrimRight(line, "\r"), "=")
+		if !found || !isValidEnvKey(key) {
+			continue
+		}
+		if runtime.GOOS == "windows" {
+			key = strings.ToUpper(key)
+		}
+		if key == "PATH" {
+			for _, entry := range filepath.SplitList(value) {
+				if entry != "$PATH" && entry != "%PATH%" && entry != "" {
+					exportedPath = append(exportedPath, entry)
+				}
+			}
+			continue
+		}
+		exported[key] = value
+	}
+
+	path := []string{filepath.Join(idfPath, "tools")}
+	path = append(path, extraPaths...)
+	path = append(path, exportedPath...)
+	if baseEnv["PATH"] != "" {
+		path = append(path, baseEnv["PATH"])
+	}
+	exported["PATH"] = strings.Join(path, string(os.PathListSeparator))
+
+	return diffEnvironments(baseEnv, exported), nil
+}
+
+func diffEnvironments(base, exported map[string]string) EnvDelta {
+	delta := EnvDelta{Vars: make(map[string]string)}
+
+	for key, value := range exported {
+		switch key {
+		case "_", "SHLVL", "PWD", "OLDPWD":
+			continue
+		case "PATH":
+			delta.PathPrepend = pathPrepended(base["PATH"], value)
+			continue
+		}
+
+		if baseValue, ok := base[key]; !ok || baseValue != value {
+			delta.Vars[key] = value
+		}
+	}
+
+	return delta
+}
+
+func pathPrepended(basePath, newPath string) []string {
+	existing := make(map[string]bool)
+	for _, entry := range filepath.SplitList(basePath) {
+		existing[entry] = true
+	}
+
+	var prepend []string
+	seen := make(map[string]bool)
+	for _, entry := range filepath.SplitList(newPath) {
+		if entry == "" || existing[entry] || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		prepend = append(prepend, entry)
+	}
+	return prepend
+}
+
+func getBaseEnvironment() map[string]string {
+	env := envListToMap(os.Environ())
+
+	for key := range env {
+		if strings.HasPrefix(key, "IDF_") && key != "IDF_TOOLS_PATH" || strings.HasPrefix(key, "ESP_IDF_") || key == "OPENOCD_SCRIPTS" {
+			delete(env, key)
+		}
+	}
+
+	prefixes := []string{getIDFToolsPath(), getESPBase()}
+	var kept []string
+	for _, entry := range filepath.SplitList(env["PATH"]) {
+		idfEntry := false
+		for _, prefix := range prefixes {
+			if rel, err := filepath.Rel(prefix, entry); err == nil && !strings.HasPrefix(rel, "..") {
+				idfEntry = true
+				break
+			}
+		}
+		if !idfEntry {
+			kept = append(kept, entry)
+		}
+	}
+	env["PATH"] = strings.Join(kept, string(os.PathListSeparator))
+
+	return env
+}
+
+func applyEnvDelta(environ []string, delta EnvDelta) []string {
+	env := envListToMap(environ)
+
+	for key, value := range delta.Vars {
+		env[key] = value
+	}
+
+	if len(delta.PathPrepend) > 0 {
+		prepend := make(map[string]bool)
+		for _, entry := range delta.PathPrepend {
+			prepend[entry] = true
+		}
+
+		path := append([]string{}, delta.PathPrepend...)
+		for _, entry := range filepath.SplitList(env["PATH"]) {
+			if !prepend[entry] {
+				path = append(path, entry)
+			}
+		}
+		env["PATH"] = strings.Join(path, string(os.PathListSeparator))
+	}
+
+	return envMapToList(env)
+}
+
+func computeEnvFingerprint(idfPath string, delta EnvDelta) map[string]string {
+	files := []string{
+		filepath.Join(idfPath, "tools", "tools.json"),
+		filepath.Join(idfPath, "tools", "idf_tools.py"),
+		filepath.Join(getIDFToolsPath(), "idf-env.json"),
+	}
+
+	if pythonEnv := delta.Vars["IDF_PYTHON_ENV_PATH"]; pythonEnv != "" {
+		files = append(files, pythonEnv, filepath.Join(pythonEnv, "pyvenv.cfg"))
+	}
+
+	fingerprint := make(map[string]string)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			fingerprint[file] = "missing"
+			continue
+		}
+		fingerprint[file] = fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+	}
+	return fingerprint
+}
+
+func fingerprintsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func getEnvCachePath(idfPath string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(idfPath)))
+	return filepath.Join(getESPBase(), ".cache", "env", hex.EncodeToString(sum[:8])+".json")
+}
+
+func readEnvCache(idfPath string) (*envCacheEntry, error) {
+	data, err := os.ReadFile(getEnvCachePath(idfPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var entry envCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry.IDFPath != idfPath {
+		return nil, fmt.Errorf("cache entry belongs to %s", entry.IDFPath)
+	}
+	return &entry, nil
+}
+
+func envListToMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, entry := range environ {
+		if key, value, found := strings.Cut(entry, "="); found && key != "" {
+			if runtime.GOOS == "windows" {
+				key = strings.ToUpper(key)
+			}
+			env[key] = value
+		}
+	}
+	return env
+}
+
+func envMapToList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for key, value := range env {
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -5,11 +5,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var execToolchain string
+
+var execCompileActions = []string{
+	"all", "build", "app", "bootloader", "flash", "app-flash", "bootloader-flash",
+	"encrypted-flash", "encrypted-app-flash", "size", "size-components", "size-files",
+}
+
 var execCmd = &cobra.Command{
 	Use:   "exec [idf.py args...]",
 	Short: "Execute idf.py command with proper environment",
@@ -17,7 +23,8 @@ var execCmd = &cobra.Command{
 	Example: `  idfmgr exec menuconfig
   idfmgr exec -p /dev/ttyUSB0 monitor
   idfmgr exec app-flash
-  idfmgr exec erase-flash`,
+  idfmgr exec erase-flash
+  idfmgr exec --toolchain clang menuconfig`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := execIdfPy(args); err != nil {
@@ -28,40 +35,63 @@ var execCmd = &cobra.Command{
 }
 
 func init() {
+	execCmd.Flags().StringVar(&execToolchain, "toolchain", "", "Toolchain whose build directory to use: gcc or clang (default: from idfmgr.toml, or gcc)")
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.RegisterFlagCompletionFunc("toolchain", completeToolchains)
 	rootCmd.AddCommand(execCmd)
 }
 
 func execIdfPy(args []string) error {
-	versionFile := ".espidf-version"
-	if _, err := os.Stat(versionFile); os.IsNotExist(err) {
-		return fmt.Errorf(".espidf-version file not found. Are you in an ESP-IDF project directory?")
-	}
-
-	versionData, err := os.ReadFile(versionFile)
+	_, idfPath, err := resolveProjectIDF()
 	if err != nil {
-		return fmt.Errorf("failed to read .espidf-version: %w", err)
+		return err
 	}
 
-	version := strings.TrimSpace(string(versionData))
-	idfPath := filepath.Join(getESPBase(), version)
-
-	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
-		return fmt.Errorf("ESP-IDF version %s is not installed. Install it with: idfmgr install %s", version, version)
+	manifest, err := getProjectManifest()
+	if err != nil {
+		return err
 	}
 
 	env, err := getESPIDFEnvironment(idfPath)
 	if err != nil {
 		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
 	}
+	env = applyManifestEnv(env, manifest)
 
 	idfPyPath := filepath.Join(idfPath, "tools", "idf.py")
 
-	cmdArgs := append([]string{idfPyPath}, args...)
+	projectDir := getProjectDir()
+	config, err := resolveBuildConfig(manifest, "", execToolchain, projectDir)
+	if err != nil {
+		return err
+	}
+	if compilesProject(args) {
+		if err := config.Toolchain.checkInstalled(env, toolchainTarget(config.Target, projectDir)); err != nil {
+			return err
+		}
+	}
+
+	cmdArgs := []string{idfPyPath}
+	if config.BuildDir != "build" && !containsString(args, "-B") && !containsString(args, "--build-dir") {
+		cmdArgs = append(cmdArgs, "-B", config.BuildDir)
+		cmdArgs = append(cmdArgs, config.Toolchain.CMakeArgs...)
+	}
+	cmdArgs = append(cmdArgs, args...)
 	cmd := exec.Command("python3", cmdArgs...)
+	cmd.Dir = projectDir
 	cmd.Env = env
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 
 	return cmd.Run()
+}
+
+func compilesProject(args []string) bool {
+	for _, arg := range args {
+		if containsString(execCompileActions, arg) {
+			return true
+		}
+	}
+	return false
 }
\ No newline at end of file
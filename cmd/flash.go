@@ -10,9 +10,12 @@ import (
 )
 
 var (
-	flashClang  bool
-	openMonitor bool
-	flashPort   string
+	flashClang     bool
+	flashToolchain string
+	openMonitor    bool
+	flashPort      string
+	flashBaud      int
+	flashProfile   string
 )
 
 var flashCmd = &cobra.Command{
@@ -21,9 +24,10 @@ var flashCmd = &cobra.Command{
 	Long:  `Flash the built project to the connected ESP32 device and optionally open serial monitor`,
 	Args:  cobra.NoArgs,
 	Example: `  idfmgr flash
-  idfmgr flash --clang
+  idfmgr flash --toolchain clang
   idfmgr flash --monitor
-  idfmgr flash --clang --monitor --port /dev/ttyUSB0`,
+  idfmgr flash --clang --monitor --port /dev/ttyUSB0
+  idfmgr flash --profile release`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := flashProject(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error flashing project: %v\n", err)
@@ -33,57 +37,62 @@ var flashCmd = &cobra.Command{
 }
 
 func init() {
-	flashCmd.Flags().BoolVar(&flashClang, "clang", false, "Flash the Clang build")
+	flashCmd.Flags().StringVar(&flashToolchain, "toolchain", "", "Flash the build of this toolchain: gcc or clang (default: from idfmgr.toml, or gcc)")
+	flashCmd.Flags().BoolVar(&flashClang, "clang", false, "Flash the Clang build (same as --toolchain clang)")
 	flashCmd.Flags().BoolVarP(&openMonitor, "monitor", "m", false, "Open serial monitor after flashing")
 	flashCmd.Flags().StringVarP(&flashPort, "port", "p", "", "Serial port (auto-detected if not specified)")
+	flashCmd.Flags().IntVarP(&flashBaud, "baud", "b", 0, "Flash baud rate (default: from idfmgr.toml or idf.py)")
+	flashCmd.Flags().StringVar(&flashProfile, "profile", "", "Flash the build of a profile declared in idfmgr.toml")
+	flashCmd.RegisterFlagCompletionFunc("port", completeSerialPorts)
+	flashCmd.RegisterFlagCompletionFunc("toolchain", completeToolchains)
+	flashCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
 	rootCmd.AddCommand(flashCmd)
 }
 
 func flashProject() error {
-	versionFile := ".espidf-version"
-	if _, err := os.Stat(versionFile); os.IsNotExist(err) {
-		return fmt.Errorf(".espidf-version file not found. Are you in an ESP-IDF project directory?")
+	_, idfPath, err := resolveProjectIDF()
+	if err != nil {
+		return err
 	}
 
-	versionData, err := os.ReadFile(versionFile)
+	manifest, err := getProjectManifest()
 	if err != nil {
-		return fmt.Errorf("failed to read .espidf-version: %w", err)
+		return err
 	}
-	version := string(versionData)
-	version = version[:len(version)-1]
 
-	buildDir := "build"
-	if flashClang {
-		buildDir = "build-clang"
-		fmt.Println("Flashing Clang build...")
-	} else {
-		fmt.Println("Flashing GCC build...")
+	projectDir := getProjectDir()
+	toolchain, err := toolchainFlag(flashToolchain, flashClang)
+	if err != nil {
+		return err
 	}
-
-	if _, err := os.Stat(buildDir); os.IsNotExist(err) {
-		buildCommand := "idfmgr build"
-		if flashClang {
-			buildCommand = "idfmgr build --clang"
-		}
-		return fmt.Errorf("%s build directory not found. Build first with: %s", buildDir, buildCommand)
+	config, err := resolveBuildConfig(manifest, flashProfile, toolchain, projectDir)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("Flashing %s/ (%s)...\n", config.BuildDir, config.description())
 
-	idfPath := filepath.Join(getESPBase(), version)
-	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
-		return fmt.Errorf("ESP-IDF version %s is not installed. Install it with: idfmgr install %s", version, version)
+	if _, err := os.Stat(filepath.Join(projectDir, config.BuildDir)); os.IsNotExist(err) {
+		return fmt.Errorf("%s build directory not found. Build first with: %s", config.BuildDir, config.buildCommand())
 	}
 
 	env, err := getESPIDFEnvironment(idfPath)
 	if err != nil {
 		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
 	}
+	env = config.applyEnv(applyManifestEnv(env, manifest))
+	if err := config.Toolchain.checkInstalled(env, toolchainTarget(config.Target, projectDir)); err != nil {
+		return err
+	}
 
 	idfPyPath := filepath.Join(idfPath, "tools", "idf.py")
-	cmdArgs := []string{idfPyPath, "-B", buildDir}
+	cmdArgs := []string{idfPyPath, "-B", config.BuildDir}
 
 	if flashPort != "" {
 		cmdArgs = append(cmdArgs, "-p", flashPort)
 	}
+	if flashBaud > 0 {
+		cmdArgs = append(cmdArgs, "-b", fmt.Sprint(flashBaud))
+	}
 
 	if openMonitor {
 		cmdArgs = append(cmdArgs, "flash", "monitor")
@@ -92,7 +101,12 @@ func flashProject() error {
 		cmdArgs = append(cmdArgs, "flash")
 	}
 
+	if err := runManifestHook(manifest, "pre-flash", projectDir, env); err != nil {
+		return err
+	}
+
 	cmd := exec.Command("python3", cmdArgs...)
+	cmd.Dir = projectDir
 	cmd.Env = env
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -102,6 +116,10 @@ func flashProject() error {
 		return fmt.Errorf("flash failed: %w", err)
 	}
 
+	if err := runManifestHook(manifest, "post-flash", projectDir, env); err != nil {
+		return err
+	}
+
 	if !openMonitor {
 		fmt.Println("Flash successful!")
 		fmt.Println("Tip: Use 'idfmgr flash --monitor' to open serial monitor after flashing")
@@ -109,4 +127,3 @@ func flashProject() error {
 
 	return nil
 }
-
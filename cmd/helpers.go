@@ -8,10 +8,25 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 )
 
+var supportedTargets = []string{
+	"esp32",
+	"esp32s2",
+	"esp32s3",
+	"esp32c2",
+	"esp32c3",
+	"esp32c5",
+	"esp32c6",
+	"esp32c61",
+	"esp32h2",
+	"esp32p4",
+}
+
 func getESPBase() string {
 	espBase := os.Getenv("ESP_BASE")
 	if espBase == "" {
@@ -24,6 +39,41 @@ func getESPBase() string {
 	return espBase
 }
 
+func getIDFToolsPath() string {
+	toolsPath := os.Getenv("IDF_TOOLS_PATH")
+	if toolsPath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ".espressif"
+		}
+		toolsPath = filepath.Join(homeDir, ".espressif")
+	}
+	return toolsPath
+}
+
+func parseIDFVersion(version string) (major, minor, patch int, ok bool) {
+	v := strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, 0, false
+	}
+
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, 0, 0, false
+		}
+		nums[i] = n
+	}
+
+	return nums[0], nums[1], nums[2], true
+}
+
 func getLatestESPIDFVersion() (string, error) {
 	resp, err := http.Get("https://api.github.com/repos/espressif/esp-idf/releases/latest")
 	if err != nil {
@@ -63,15 +113,240 @@ func getLatestInstalledESPIDFVersion() (string, error) {
 }
 
 func getESPIDFEnvironment(idfPath string) ([]string, error) {
-	exportScript := filepath.Join(idfPath, "export.sh")
+	recordVersionUse(idfPath)
+
+	delta, err := loadEnvDelta(idfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyEnvDelta(os.Environ(), delta), nil
+}
+
+func getSystemPython() string {
+	if runtime.GOOS == "windows" || !commandExists("python3") {
+		return "python"
+	}
+	return "python3"
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
 
-	cmd := exec.Command("bash", "-c", fmt.Sprintf("source %s > /dev/null 2>&1 && env", exportScript))
-	output, err := cmd.Output()
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
 	if err != nil {
-		return nil, fmt.Errorf("failed to source export.sh: %w", err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
 	}
 
-	envVars := strings.Split(string(output), "\n")
-	return envVars, nil
+	return os.Rename(tmp.Name(), path)
+}
+
+func readProjectVersion() (string, error) {
+	root, err := getProjectRoot()
+	if err != nil {
+		return "", err
+	}
+
+	return readProjectVersionAt(root)
+}
+
+func resolveProjectIDF() (version, idfPath string, err error) {
+	return resolveIDF(readProjectVersion)
 }
 
+func resolveIDF(readPin func() (string, error)) (version, idfPath string, err error) {
+	version, idfPath, err = selectIDF(readPin)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
+		if err := installMissingVersion(version); err != nil {
+			return "", "", err
+		}
+	}
+	return version, idfPath, nil
+}
+
+func findProjectIDF() (version, idfPath string, err error) {
+	version, idfPath, err = selectIDF(readProjectVersion)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
+		return "", "", fmt.Errorf("ESP-IDF version %s is not installed. Install it with: idfmgr install %s", version, version)
+	}
+	return version, idfPath, nil
+}
+
+func selectIDF(readPin func() (string, error)) (version, idfPath string, err error) {
+	if idfPathOverride != "" {
+		idfPath, err = filepath.Abs(idfPathOverride)
+		if err != nil {
+			return "", "", err
+		}
+		if !isValidESPIDFInstall(idfPath) {
+			return "", "", fmt.Errorf("%s is not an ESP-IDF installation", idfPath)
+		}
+		return readIDFVersion(idfPath), idfPath, nil
+	}
+
+	version = idfVersionOverride
+	if version == "" {
+		version = os.Getenv("IDFMGR_VERSION")
+	}
+
+	activePath := getActiveIDFPath()
+	if version == "" {
+		version, err = readPin()
+		if err != nil {
+			if activePath == "" {
+				return "", "", err
+			}
+			return readIDFVersion(activePath), activePath, nil
+		}
+	}
+
+	idfPath = filepath.Join(getESPBase(), version)
+	if activePath != "" && filepath.Clean(activePath) != filepath.Clean(idfPath) {
+		activeVersion := readIDFVersion(activePath)
+		if activeVersion == version {
+			return version, activePath, nil
+		}
+		fmt.Fprintf(os.Stderr, "Warning: IDF_PATH points to %s (%s), but %s is requested. Using %s\n", activePath, activeVersion, version, idfPath)
+	}
+	return version, idfPath, nil
+}
+
+func getActiveIDFPath() string {
+	activePath := os.Getenv("IDF_PATH")
+	if activePath == "" || !isValidESPIDFInstall(activePath) {
+		return ""
+	}
+	return activePath
+}
+
+func readIDFVersion(idfPath string) string {
+	if filepath.Clean(filepath.Dir(idfPath)) == filepath.Clean(getESPBase()) {
+		return filepath.Base(idfPath)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(idfPath, "version.txt")); err == nil {
+		if version := strings.TrimSpace(string(data)); version != "" {
+			return version
+		}
+	}
+
+	if output, err := exec.Command("git", "-C", idfPath, "describe", "--tags").Output(); err == nil {
+		if version := strings.TrimSpace(string(output)); version != "" {
+			return version
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(idfPath, "tools", "cmake", "version.cmake"))
+	if err != nil {
+		return "unknown"
+	}
+	parts := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		var key, value string
+		if n, _ := fmt.Sscanf(strings.TrimSpace(line), "set(%s %s", &key, &value); n == 2 {
+			parts[key] = strings.TrimSuffix(value, ")")
+		}
+	}
+	if parts["IDF_VERSION_MAJOR"] == "" {
+		return "unknown"
+	}
+	return fmt.Sprintf("v%s.%s.%s", parts["IDF_VERSION_MAJOR"], parts["IDF_VERSION_MINOR"], parts["IDF_VERSION_PATCH"])
+}
+
+func findProjectRoot(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		for _, name := range []string{".espidf-version", manifestFile} {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+				return dir, true
+			}
+		}
+		if isRepositoryRoot(dir) {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// isRepositoryRoot reports whether dir is the top of a git repository. A submodule has a
+// .git file pointing into the superproject's .git/modules, so the search continues upwards.
+func isRepositoryRoot(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, ".git"))
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		return true
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".git"))
+	if err != nil {
+		return true
+	}
+	gitDir := filepath.ToSlash(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(data)), "gitdir:")))
+	return !strings.Contains("/"+gitDir+"/", "/modules/")
+}
+
+func getProjectRoot() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	root, found := findProjectRoot(cwd)
+	if !found {
+		return "", fmt.Errorf(".espidf-version or %s not found in %s or its parent directories. Are you in an ESP-IDF project directory?", manifestFile, cwd)
+	}
+	return root, nil
+}
+
+func getProjectDir() string {
+	if root, err := getProjectRoot(); err == nil {
+		return root
+	}
+	return "."
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+const hookRootVar = "IDFMGR_HOOK_ROOT"
+
+var hookEnvShell string
+
+var hookCmd = &cobra.Command{
+	Use:   "hook <bash|zsh|fish>",
+	Short: "Print a shell hook that activates ESP-IDF when entering a project",
+	Long: `Print a shell hook that activates the pinned ESP-IDF version whenever you enter a
+directory containing .espidf-version (or one of its subdirectories), and restores the
+previous environment when you leave it. Add it to your shell's startup file.`,
+	Example: `  echo 'eval "$(idfmgr hook bash)"' >> ~/.bashrc
+  echo 'eval "$(idfmgr hook zsh)"' >> ~/.zshrc
+  echo 'idfmgr hook fish | source' >> ~/.config/fish/config.fish`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := printHook(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error printing hook: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var hookEnvCmd = &cobra.Command{
+	Use:    "hook-env",
+	Short:  "Print the environment changes for the current directory (used by the shell hook)",
+	Args:   cobra.NoArgs,
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := printHookEnv(); err != nil {
+			fmt.Fprintf(os.Stderr, "idfmgr: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	hookEnvCmd.Flags().StringVar(&hookEnvShell, "shell", "", "Shell to print commands for")
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(hookEnvCmd)
+}
+
+func printHook(shell string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "idfmgr"
+	}
+
+	switch shell {
+	case "bash":
+		fmt.Printf(`_idfmgr_hook() {
+  local previous_exit_status=$?
+  if [[ "$PWD" != "${_IDFMGR_HOOK_PWD-}" ]]; then
+    _IDFMGR_HOOK_PWD="$PWD"
+    eval "$(%s hook-env --shell bash)"
+  fi
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND:-};" != *";_idfmgr_hook;"* ]]; then
+  PROMPT_COMMAND="_idfmgr_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`, posixQuote(exe))
+	case "zsh":
+		fmt.Printf(`_idfmgr_hook() {
+  eval "$(%s hook-env --shell zsh)"
+}
+typeset -ag chpwd_functions
+if (( ! ${chpwd_functions[(I)_idfmgr_hook]} )); then
+  chpwd_functions=(_idfmgr_hook $chpwd_functions)
+fi
+_idfmgr_hook
+`, posixQuote(exe))
+	case "fish":
+		fmt.Printf(`function _idfmgr_hook --on-variable PWD
+    %s hook-env --shell fish | source
+end
+_idfmgr_hook
+`, fishQuote(exe))
+	default:
+		return fmt.Errorf("unsupported shell %q (supported: bash, zsh, fish)", shell)
+	}
+	return nil
+}
+
+func printHookEnv() error {
+	current := envListToMap(os.Environ())
+
+	if current["IDFMGR_ACTIVE"] != "" {
+		return nil
+	}
+	hookRoot, hookActive := current[hookRootVar]
+	if _, ok := current[savedEnvVar]; ok && !hookActive {
+		return nil
+	}
+
+	shell, err := resolveShell(hookEnvShell)
+	if err != nil {
+		return err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var target map[string]string
+	root, found := findProjectRoot(cwd)
+	if found {
+		target, err = hookActivatedEnvironment(current, root, hookRoot)
+		if err != nil {
+			return err
+		}
+	}
+
+	if target == nil {
+		if !hookActive {
+			return nil
+		}
+		target, err = restoredEnvironment(current)
+		if err != nil {
+			return err
+		}
+		delete(target, hookRootVar)
+		fmt.Fprintln(os.Stderr, "idfmgr: deactivated ESP-IDF environment")
+	}
+
+	fmt.Print(renderShellChanges(shell, diffEnvMaps(current, target)))
+	return nil
+}
+
+func hookActivatedEnvironment(current map[string]string, root, hookRoot string) (map[string]string, error) {
+	version, err := readProjectVersionAt(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if hookRoot == root {
+		if saved, err := decodeSavedEnvironment(current[savedEnvVar]); err == nil && saved.Version == version {
+			return current, nil
+		}
+	}
+
+	idfPath := filepath.Join(getESPBase(), version)
+	if !isValidESPIDFInstall(idfPath) {
+		fmt.Fprintf(os.Stderr, "idfmgr: ESP-IDF %s is not installed. Install it with: idfmgr install %s\n", version, version)
+		return nil, nil
+	}
+
+	recordVersionUse(idfPath)
+	delta, err := loadEnvDelta(idfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := activatedEnvironment(current, delta, version)
+	if err != nil {
+		return nil, err
+	}
+	target[hookRootVar] = root
+	fmt.Fprintf(os.Stderr, "idfmgr: activated ESP-IDF %s\n", version)
+	return target, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -26,18 +27,16 @@ func init() {
 }
 
 func showInfo() error {
-	versionFile := ".espidf-version"
-	if _, err := os.Stat(versionFile); os.IsNotExist(err) {
-		return fmt.Errorf(".espidf-version file not found. Are you in an ESP-IDF project directory?")
+	version, idfPath, err := selectIDF(readProjectVersion)
+	if err != nil {
+		return err
 	}
 
-	versionData, err := os.ReadFile(versionFile)
+	manifest, err := getProjectManifest()
 	if err != nil {
-		return fmt.Errorf("failed to read .espidf-version: %w", err)
+		return err
 	}
 
-	version := strings.TrimSpace(string(versionData))
-	idfPath := filepath.Join(getESPBase(), version)
 	exportScript := filepath.Join(idfPath, "export.sh")
 
 	fmt.Println("Project Information")
@@ -45,29 +44,97 @@ func showInfo() error {
 	fmt.Printf("ESP-IDF Version: %s\n", version)
 	fmt.Printf("IDF Path:        %s\n", idfPath)
 
+	if manifest != nil {
+		fmt.Printf("\nManifest (%s)\n", manifestFile)
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+		if len(manifest.Targets) > 0 {
+			fmt.Printf("Targets:         %s\n", strings.Join(manifest.Targets, ", "))
+		}
+		if manifest.Toolchain != "" {
+			fmt.Printf("Toolchain:       %s\n", manifest.Toolchain)
+		}
+		if manifest.Flash.Port != "" {
+			fmt.Printf("Flash Port:      %s\n", manifest.Flash.Port)
+		}
+		if manifest.Flash.Baud > 0 {
+			fmt.Printf("Flash Baud:      %d\n", manifest.Flash.Baud)
+		}
+		if profiles := manifestProfileNames(manifest); len(profiles) > 0 {
+			fmt.Printf("Profiles:        %s\n", strings.Join(profiles, ", "))
+		}
+		if len(manifest.Env) > 0 {
+			keys := make([]string, 0, len(manifest.Env))
+			for key := range manifest.Env {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			fmt.Printf("Environment:     %s\n", strings.Join(keys, ", "))
+		}
+		for _, hook := range []struct{ name, command string }{
+			{"pre-build", manifest.Hooks.PreBuild},
+			{"post-build", manifest.Hooks.PostBuild},
+			{"pre-flash", manifest.Hooks.PreFlash},
+			{"post-flash", manifest.Hooks.PostFlash},
+		} {
+			if hook.command != "" {
+				fmt.Printf("Hook %-11s %s\n", hook.name+":", hook.command)
+			}
+		}
+	}
+
 	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
 		fmt.Printf("\n   ESP-IDF version %s is not installed\n", version)
 		fmt.Printf("Install it with: idfmgr install %s\n", version)
 		return nil
 	}
 
-	hasBuildGcc := false
-	hasBuildClang := false
-	if _, err := os.Stat("build"); err == nil {
-		hasBuildGcc = true
-	}
-	if _, err := os.Stat("build-clang"); err == nil {
-		hasBuildClang = true
+	projectDir := getProjectDir()
+	var buildDirs []string
+	for _, profile := range append([]string{""}, manifestProfileNames(manifest)...) {
+		for _, toolchain := range toolchainNames() {
+			config, err := resolveBuildConfig(manifest, profile, toolchain, projectDir)
+			if err != nil {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(projectDir, config.BuildDir)); err != nil {
+				continue
+			}
+			if profile == "" {
+				buildDirs = append(buildDirs, fmt.Sprintf("✓ %s build directory exists (%s/)", config.Toolchain.Label, config.BuildDir))
+			} else {
+				buildDirs = append(buildDirs, fmt.Sprintf("✓ Profile %s %s build directory exists (%s/)", profile, config.Toolchain.Label, config.BuildDir))
+			}
+		}
 	}
 
-	if hasBuildGcc || hasBuildClang {
+	if len(buildDirs) > 0 {
 		fmt.Println("\nBuild Status")
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
-		if hasBuildGcc {
-			fmt.Println("✓ GCC build directory exists (build/)")
+		for _, line := range buildDirs {
+			fmt.Println(line)
 		}
-		if hasBuildClang {
-			fmt.Println("✓ Clang build directory exists (build-clang/)")
+	}
+
+	if env, err := getESPIDFEnvironment(idfPath); err == nil {
+		target := ""
+		if manifest != nil && len(manifest.Targets) > 0 {
+			target = manifest.Targets[0]
+		}
+		target = toolchainTarget(target, projectDir)
+
+		fmt.Println("\nToolchains")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+		for i := range toolchains {
+			toolchain := &toolchains[i]
+			label := toolchain.Label
+			if toolchain.Name == manifestToolchain(manifest) {
+				label += " (project default)"
+			}
+			if err := toolchain.checkInstalled(env, target); err != nil {
+				fmt.Printf("✗ %s for %s is not installed. Install it with: %s\n", label, target, toolchain.installHint(target))
+			} else {
+				fmt.Printf("✓ %s\n", label)
+			}
 		}
 	}
 
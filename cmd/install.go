@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -16,6 +19,16 @@ var (
 	skipClang   bool
 )
 
+const installManifestFile = ".idfmgr-install.json"
+
+type InstallManifest struct {
+	Version     string    `json:"version"`
+	Source      string    `json:"source"`
+	Targets     []string  `json:"targets"`
+	Clang       bool      `json:"clang"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
 var installCmd = &cobra.Command{
 	Use:   "install <version>",
 	Short: "Install a specific ESP-IDF version",
@@ -28,6 +41,7 @@ var installCmd = &cobra.Command{
 			os.Exit(1)
 		}
 	},
+	ValidArgsFunction: completeFirstArg(completeRemoteVersions),
 }
 
 func init() {
@@ -37,7 +51,11 @@ func init() {
 }
 
 func installVersion(version string) error {
-	fmt.Printf("Installing ESP-IDF version %s...\n", version)
+	return installVersionForTargets(os.Stdout, version, []string{"esp32"})
+}
+
+func installVersionForTargets(out io.Writer, version string, targets []string) error {
+	fmt.Fprintf(out, "Installing ESP-IDF version %s...\n", version)
 
 	if version == "latest" {
 		var err error
@@ -51,12 +69,12 @@ func installVersion(version string) error {
 	installPath := filepath.Join(espBase, version)
 
 	if _, err := os.Stat(installPath); err == nil {
-		fmt.Printf("Version %s is already installed at %s\n", version, installPath)
+		fmt.Fprintf(out, "Version %s is already installed at %s\n", version, installPath)
 		return nil
 	}
 
 	if !skipPrereqs {
-		if err := checkPrerequisites(); err != nil {
+		if err := checkPrerequisites(out); err != nil {
 			return fmt.Errorf("prerequisite check failed: %w", err)
 		}
 	}
@@ -65,27 +83,40 @@ func installVersion(version string) error {
 		return fmt.Errorf("failed to create ESP_BASE directory: %w", err)
 	}
 
-	if err := cloneESPIDF(version, installPath); err != nil {
+	if err := cloneESPIDF(out, version, installPath); err != nil {
 		return fmt.Errorf("failed to clone ESP-IDF: %w", err)
 	}
 
-	if err := runInstallScript(installPath); err != nil {
+	if err := runInstallScript(out, installPath, targets, nil); err != nil {
 		return fmt.Errorf("failed to run install script: %w", err)
 	}
 
+	clang := false
 	if !skipClang {
-		if err := installESPClang(installPath); err != nil {
-			fmt.Printf("Warning: Failed to install esp-clang: %v\n", err)
-			fmt.Println("You can install it manually later with: idf_tools.py install esp-clang")
+		if err := installESPClang(out, installPath); err != nil {
+			fmt.Fprintf(out, "Warning: Failed to install esp-clang: %v\n", err)
+			fmt.Fprintln(out, "You can install it manually later with: idf_tools.py install esp-clang")
+		} else {
+			clang = true
 		}
 	}
 
-	fmt.Printf("ESP-IDF version %s installed successfully at %s\n", version, installPath)
+	if err := writeInstallManifest(installPath, InstallManifest{
+		Version:     version,
+		Source:      "git",
+		Targets:     targets,
+		Clang:       clang,
+		InstalledAt: time.Now(),
+	}); err != nil {
+		fmt.Fprintf(out, "Warning: Could not write install manifest: %v\n", err)
+	}
+
+	fmt.Fprintf(out, "ESP-IDF version %s installed successfully at %s\n", version, installPath)
 	return nil
 }
 
-func checkPrerequisites() error {
-	fmt.Println("Checking prerequisites...")
+func checkPrerequisites(out io.Writer) error {
+	fmt.Fprintln(out, "Checking prerequisites...")
 
 	prerequisites := []string{
 		"git", "wget", "python3", "cmake", "ninja",
@@ -99,45 +130,89 @@ func checkPrerequisites() error {
 	}
 
 	if len(missing) > 0 {
-		fmt.Printf("Missing prerequisites: %s\n", strings.Join(missing, ", "))
-		fmt.Println("\nPlease install them using your package manager:")
+		fmt.Fprintf(out, "Missing prerequisites: %s\n", strings.Join(missing, ", "))
+		fmt.Fprintln(out, "\nPlease install them using your package manager:")
 
 		switch runtime.GOOS {
 		case "linux":
 			if commandExists("apt-get") {
-				fmt.Printf("  sudo apt-get install %s\n", strings.Join(missing, " "))
+				fmt.Fprintf(out, "  sudo apt-get install %s\n", strings.Join(missing, " "))
 			} else if commandExists("yum") {
-				fmt.Printf("  sudo yum install %s\n", strings.Join(missing, " "))
+				fmt.Fprintf(out, "  sudo yum install %s\n", strings.Join(missing, " "))
 			} else if commandExists("pacman") {
-				fmt.Printf("  sudo pacman -S %s\n", strings.Join(missing, " "))
+				fmt.Fprintf(out, "  sudo pacman -S %s\n", strings.Join(missing, " "))
 			} else {
-				fmt.Println("  Install using your distribution's package manager")
+				fmt.Fprintln(out, "  Install using your distribution's package manager")
 			}
 		case "darwin":
 			if commandExists("brew") {
-				fmt.Printf("  brew install %s\n", strings.Join(missing, " "))
+				fmt.Fprintf(out, "  brew install %s\n", strings.Join(missing, " "))
 			} else {
-				fmt.Println("  Install Homebrew first: https://brew.sh")
-				fmt.Printf("  Then: brew install %s\n", strings.Join(missing, " "))
+				fmt.Fprintln(out, "  Install Homebrew first: https://brew.sh")
+				fmt.Fprintf(out, "  Then: brew install %s\n", strings.Join(missing, " "))
 			}
 		case "windows":
-			fmt.Println("  Install using chocolatey, winget, or download manually")
+			fmt.Fprintln(out, "  Install using chocolatey, winget, or download manually")
 		}
 
 		return fmt.Errorf("missing prerequisites")
 	}
 
-	fmt.Println("All prerequisites are installed")
+	fmt.Fprintln(out, "All prerequisites are installed")
+	return nil
+}
+
+func installMissingVersion(version string) error {
+	notInstalled := fmt.Errorf("ESP-IDF version %s is not installed. Install it with: idfmgr install %s (or use --auto-install)", version, version)
+
+	install := autoInstall
+	switch strings.ToLower(os.Getenv("IDFMGR_AUTO_INSTALL")) {
+	case "1", "true", "yes":
+		install = true
+	}
+
+	if !install {
+		if !isInteractive() {
+			return notInstalled
+		}
+		confirmed, err := confirmOn(os.Stderr, fmt.Sprintf("ESP-IDF version %s is not installed. Install it now?", version))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return notInstalled
+		}
+	}
+
+	targets := []string{"esp32"}
+	if manifest, err := getProjectManifest(); err == nil && manifest != nil && len(manifest.Targets) > 0 {
+		targets = manifest.Targets
+	}
+
+	if err := installVersionForTargets(os.Stderr, version, targets); err != nil {
+		return fmt.Errorf("failed to install ESP-IDF version %s: %w", version, err)
+	}
+	fmt.Fprintln(os.Stderr)
 	return nil
 }
 
+func isInteractive() bool {
+	for _, file := range []*os.File{os.Stdin, os.Stderr} {
+		info, err := file.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func commandExists(cmd string) bool {
 	_, err := exec.LookPath(cmd)
 	return err == nil
 }
 
-func cloneESPIDF(version, installPath string) error {
-	fmt.Printf("Cloning ESP-IDF %s...\n", version)
+func cloneESPIDF(out io.Writer, version, installPath string) error {
+	fmt.Fprintf(out, "Cloning ESP-IDF %s...\n", version)
 
 	cmd := exec.Command("git", "clone",
 		"-b", version,
@@ -146,29 +221,27 @@ func cloneESPIDF(version, installPath string) error {
 		"https://github.com/espressif/esp-idf.git",
 		installPath)
 
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = out
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("git clone failed: %w", err)
 	}
 
-	fmt.Println("ESP-IDF cloned successfully")
+	fmt.Fprintln(out, "ESP-IDF cloned successfully")
 	return nil
 }
 
-func runInstallScript(installPath string) error {
-	fmt.Println("Running ESP-IDF install script...")
+func runInstallScript(out io.Writer, installPath string, targets []string, extraEnv []string) error {
+	fmt.Fprintln(out, "Running ESP-IDF install script...")
 
 	var installScript string
-	var args []string
+	args := []string{strings.Join(targets, ",")}
 
 	if runtime.GOOS == "windows" {
 		installScript = filepath.Join(installPath, "install.bat")
-		args = []string{"esp32"}
 	} else {
 		installScript = filepath.Join(installPath, "install.sh")
-		args = []string{"esp32"}
 	}
 
 	if _, err := os.Stat(installScript); os.IsNotExist(err) {
@@ -177,23 +250,26 @@ func runInstallScript(installPath string) error {
 
 	cmd := exec.Command(installScript, args...)
 	cmd.Dir = installPath
-	cmd.Stdout = os.Stdout
+	if len(extraEnv) > 0 {
+		cmd.Env = append(os.Environ(), extraEnv...)
+	}
+	cmd.Stdout = out
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("install script failed: %w", err)
 	}
 
-	fmt.Println("ESP-IDF install script completed")
+	fmt.Fprintln(out, "ESP-IDF install script completed")
 	return nil
 }
 
-func installESPClang(installPath string) error {
-	fmt.Println("Installing esp-clang...")
+func installESPClang(out io.Writer, installPath string) error {
+	fmt.Fprintln(out, "Installing esp-clang...")
 
 	cmd := exec.Command("pip3", "install", "-U", "pyclang")
 	if err := cmd.Run(); err != nil {
-		fmt.Println("Warning: Failed to install pyclang via pip3")
+		fmt.Fprintln(out, "Warning: Failed to install pyclang via pip3")
 	}
 
 	idfToolsScript := filepath.Join(installPath, "tools", "idf_tools.py")
@@ -203,14 +279,34 @@ func installESPClang(installPath string) error {
 
 	cmd = exec.Command("python3", idfToolsScript, "install", "esp-clang")
 	cmd.Dir = installPath
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = out
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("esp-clang installation failed: %w", err)
 	}
 
-	fmt.Println("esp-clang installed successfully")
+	fmt.Fprintln(out, "esp-clang installed successfully")
 	return nil
 }
 
+func writeInstallManifest(installPath string, manifest InstallManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(installPath, installManifestFile), data, 0o644)
+}
+
+func readInstallManifest(installPath string) (*InstallManifest, error) {
+	data, err := os.ReadFile(filepath.Join(installPath, installManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest InstallManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", installManifestFile, err)
+	}
+	return &manifest, nil
+}
@@ -59,11 +59,24 @@ func listInstalledVersions() error {
 
 	sort.Strings(versions)
 
-	fmt.Printf("%-15s %s\n", "VERSION", "PATH")
-	fmt.Printf("%s\n", strings.Repeat("-", 50))
-	
+	fmt.Printf("%-15s %-10s %s\n", "VERSION", "PROJECTS", "PATH")
+	fmt.Printf("%s\n", strings.Repeat("-", 60))
+
+	byVersion := projectsByVersion()
+	var dependents []string
 	for _, version := range versions {
-		fmt.Printf("%-15s %s\n", version, filepath.Join(espBase, version))
+		projects := byVersion[version]
+		fmt.Printf("%-15s %-10d %s\n", version, len(projects), filepath.Join(espBase, version))
+		for _, project := range projects {
+			dependents = append(dependents, fmt.Sprintf("  %-15s %s", version, project.Path))
+		}
+	}
+
+	if len(dependents) > 0 {
+		fmt.Printf("\nRegistered projects:\n")
+		for _, line := range dependents {
+			fmt.Println(line)
+		}
 	}
 
 	fmt.Printf("\nTotal: %d version(s) installed\n", len(versions))
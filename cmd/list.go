@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -41,24 +42,9 @@ func init() {
 }
 
 func listAvailableVersions() error {
-	resp, err := http.Get("https://api.github.com/repos/espressif/esp-idf/releases")
+	releases, err := fetchReleases(http.DefaultClient)
 	if err != nil {
-		return fmt.Errorf("failed to fetch releases: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var releases []GitHubRelease
-	if err := json.Unmarshal(body, &releases); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+		return err
 	}
 
 	fmt.Printf("\nAvailable ESP-IDF versions (showing latest 20):\n")
@@ -91,4 +77,57 @@ func listAvailableVersions() error {
 
 	fmt.Printf("\nTo install a version: idfmgr install <version>\n")
 	return nil
-}
\ No newline at end of file
+}
+
+func getReleasesCachePath() string {
+	return filepath.Join(getESPBase(), ".cache", "releases.json")
+}
+
+func fetchReleases(client *http.Client) ([]GitHubRelease, error) {
+	resp, err := client.Get("https://api.github.com/repos/espressif/esp-idf/releases")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch releases: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var releases []GitHubRelease
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	writeFileAtomic(getReleasesCachePath(), body, 0o644)
+	return releases, nil
+}
+
+func loadCachedReleases(maxAge time.Duration) ([]GitHubRelease, error) {
+	cachePath := getReleasesCachePath()
+
+	var cached []GitHubRelease
+	info, err := os.Stat(cachePath)
+	if err == nil {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			json.Unmarshal(data, &cached)
+		}
+		if time.Since(info.ModTime()) < maxAge && len(cached) > 0 {
+			return cached, nil
+		}
+	}
+
+	releases, err := fetchReleases(&http.Client{Timeout: 3 * time.Second})
+	if err != nil {
+		if len(cached) > 0 {
+			return cached, nil
+		}
+		return nil, err
+	}
+	return releases, nil
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const manifestFile = "idfmgr.toml"
+
+type ProjectManifest struct {
+	IDF       string                     `toml:"idf"`
+	Targets   []string                   `toml:"targets"`
+	Toolchain string                     `toml:"toolchain"`
+	Flash     ManifestFlash              `toml:"flash"`
+	Env       map[string]string          `toml:"env"`
+	Hooks     ManifestHooks              `toml:"hooks"`
+	Size      ManifestSize               `toml:"size"`
+	Profiles  map[string]ManifestProfile `toml:"profiles"`
+}
+
+type ManifestFlash struct {
+	Port string `toml:"port"`
+	Baud int    `toml:"baud"`
+}
+
+type ManifestHooks struct {
+	PreBuild  string `toml:"pre-build"`
+	PostBuild string `toml:"post-build"`
+	PreFlash  string `toml:"pre-flash"`
+	PostFlash string `toml:"post-flash"`
+}
+
+type ManifestSize struct {
+	Image  string            `toml:"image"`
+	Growth string            `toml:"growth"`
+	Memory map[string]string `toml:"memory"`
+}
+
+type ManifestProfile struct {
+	SdkconfigDefaults []string          `toml:"sdkconfig-defaults"`
+	Toolchain         string            `toml:"toolchain"`
+	Env               map[string]string `toml:"env"`
+}
+
+var manifestIDFLine = regexp.MustCompile(`^idf\s*=`)
+
+func loadProjectManifest(root string) (*ProjectManifest, error) {
+	path := filepath.Join(root, manifestFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var manifest ProjectManifest
+	if _, err := toml.DecodeFile(path, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+
+	if manifest.Toolchain != "" {
+		if _, err := getToolchain(manifest.Toolchain); err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestFile, err)
+		}
+	}
+	for _, target := range manifest.Targets {
+		if !containsString(supportedTargets, target) {
+			return nil, fmt.Errorf("%s: unsupported target %q (supported: %s)", manifestFile, target, strings.Join(supportedTargets, ", "))
+		}
+	}
+	sizeLimits := []string{manifest.Size.Image, manifest.Size.Growth}
+	for _, limit := range manifest.Size.Memory {
+		sizeLimits = append(sizeLimits, limit)
+	}
+	for _, limit := range sizeLimits {
+		if _, err := parseBytes(limit); limit != "" && err != nil {
+			return nil, fmt.Errorf("%s: [size]: %w", manifestFile, err)
+		}
+	}
+	for name, profile := range manifest.Profiles {
+		if !profileNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s: invalid profile name %q (use letters, digits, '-' and '_')", manifestFile, name)
+		}
+		if profile.Toolchain != "" {
+			if _, err := getToolchain(profile.Toolchain); err != nil {
+				return nil, fmt.Errorf("%s: profile %q: %w", manifestFile, name, err)
+			}
+		}
+	}
+	return &manifest, nil
+}
+
+func getProjectManifest() (*ProjectManifest, error) {
+	root, err := getProjectRoot()
+	if err != nil {
+		return nil, nil
+	}
+	return loadProjectManifest(root)
+}
+
+func readProjectVersionAt(root string) (string, error) {
+	version, pinned, err := lookupProjectVersion(root)
+	if err != nil {
+		return "", err
+	}
+	if pinned != "" && pinned != version {
+		fmt.Fprintf(os.Stderr, "Warning: .espidf-version (%s) does not match %s (%s), using %s. Sync it with: idfmgr pin %s\n", pinned, manifestFile, version, version, version)
+	}
+	return version, nil
+}
+
+func lookupProjectVersion(root string) (version, pinned string, err error) {
+	manifest, err := loadProjectManifest(root)
+	if err != nil {
+		return "", "", err
+	}
+
+	versionData, err := os.ReadFile(filepath.Join(root, ".espidf-version"))
+	if err == nil {
+		pinned = strings.TrimSpace(string(versionData))
+	} else if manifest == nil || manifest.IDF == "" {
+		return "", "", fmt.Errorf("failed to read .espidf-version: %w", err)
+	}
+
+	if manifest != nil && manifest.IDF != "" {
+		return manifest.IDF, pinned, nil
+	}
+	if pinned == "" {
+		return "", "", fmt.Errorf(".espidf-version is empty")
+	}
+	return pinned, pinned, nil
+}
+
+func writeProjectVersion(root, version string) error {
+	if err := createESPIDFVersionFile(root, version); err != nil {
+		return fmt.Errorf("failed to write .espidf-version: %w", err)
+	}
+
+	path := filepath.Join(root, manifestFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("idf = %q", version)
+	lines := strings.Split(string(data), "\n")
+	replaced := false
+	for i, current := range lines {
+		trimmed := strings.TrimSpace(current)
+		if strings.HasPrefix(trimmed, "[") {
+			break
+		}
+		if manifestIDFLine.MatchString(trimmed) {
+			lines[i] = line
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		lines = append([]string{line}, lines...)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+}
+
+func createProjectManifest(projectPath, version, target string) error {
+	content := fmt.Sprintf(`# idfmgr project manifest
+idf = %q
+targets = [%q]
+toolchain = "gcc"   # gcc or clang
+
+[flash]
+# port = "/dev/ttyUSB0"
+# baud = 921600
+
+[env]
+# MY_VARIABLE = "value"
+
+[hooks]
+# pre-build = "python3 tools/gen_version.py"
+# post-flash = "echo flashed"
+
+# Build with: idfmgr build --profile release
+# [profiles.release]
+# sdkconfig-defaults = ["sdkconfig.defaults", "sdkconfig.defaults.release"]
+`, version, target)
+	return os.WriteFile(filepath.Join(projectPath, manifestFile), []byte(content), 0o644)
+}
+
+func applyManifestEnv(env []string, manifest *ProjectManifest) []string {
+	if manifest == nil {
+		return env
+	}
+
+	vars := envListToMap(env)
+	for key, value := range manifest.Env {
+		vars[key] = os.ExpandEnv(value)
+	}
+	if manifest.Flash.Port != "" && vars["ESPPORT"] == "" {
+		vars["ESPPORT"] = manifest.Flash.Port
+	}
+	if manifest.Flash.Baud > 0 && vars["ESPBAUD"] == "" {
+		vars["ESPBAUD"] = fmt.Sprint(manifest.Flash.Baud)
+	}
+	return envMapToList(vars)
+}
+
+func runManifestHook(manifest *ProjectManifest, name, dir string, env []string) error {
+	return runManifestHookWithLabel(manifest, name, "", dir, env)
+}
+
+func runManifestHookWithLabel(manifest *ProjectManifest, name, label, dir string, env []string) error {
+	if manifest == nil {
+		return nil
+	}
+
+	command := map[string]string{
+		"pre-build":  manifest.Hooks.PreBuild,
+		"post-build": manifest.Hooks.PostBuild,
+		"pre-flash":  manifest.Hooks.PreFlash,
+		"post-flash": manifest.Hooks.PostFlash,
+	}[name]
+	if command == "" {
+		return nil
+	}
+
+	if label != "" {
+		fmt.Printf("[%s] ", label)
+	}
+	fmt.Printf("Running %s hook: %s\n", name, command)
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	return nil
+}
+
+func manifestProfileNames(manifest *ProjectManifest) []string {
+	if manifest == nil {
+		return nil
+	}
+	names := make([]string, 0, len(manifest.Profiles))
+	for name := range manifest.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,444 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const matrixLogFile = "idfmgr-build.log"
+
+type MatrixBuild struct {
+	Version string
+	IDFPath string
+	Env     []string
+	Config  *BuildConfig
+	Err     error
+}
+
+type MatrixBuildResult struct {
+	Version  string
+	Target   string
+	BuildDir string
+	Duration time.Duration
+	AppSize  int64
+	Err      error
+}
+
+func parseTargetList(list string) ([]string, error) {
+	var targets []string
+	for _, target := range strings.Split(list, ",") {
+		target = strings.TrimSpace(target)
+		if target == "" || containsString(targets, target) {
+			continue
+		}
+		if !containsString(supportedTargets, target) {
+			return nil, fmt.Errorf("unsupported target %q (supported: %s)", target, strings.Join(supportedTargets, ", "))
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets given")
+	}
+	return targets, nil
+}
+
+func resolveInstalledVersionList(list string) ([]string, error) {
+	installed, err := getInstalledVersions(getESPBase())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to get installed versions: %w", err)
+	}
+
+	if list == "all-installed" {
+		if len(installed) == 0 {
+			return nil, fmt.Errorf("no ESP-IDF versions installed")
+		}
+		sort.Strings(installed)
+		return installed, nil
+	}
+
+	var versions []string
+	for _, spec := range strings.Split(list, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		version, err := matchInstalledVersion(spec, installed)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(versions, version) {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no ESP-IDF versions given")
+	}
+	return versions, nil
+}
+
+func matchInstalledVersion(spec string, installed []string) (string, error) {
+	if containsString(installed, spec) {
+		return spec, nil
+	}
+
+	prefix := spec
+	if !strings.HasPrefix(prefix, "v") {
+		prefix = "v" + prefix
+	}
+	if containsString(installed, prefix) {
+		return prefix, nil
+	}
+
+	best, bestPatch := "", -1
+	for _, version := range installed {
+		if !strings.HasPrefix(version, prefix+".") {
+			continue
+		}
+		_, _, patch, ok := parseIDFVersion(version)
+		if !ok {
+			continue
+		}
+		if patch > bestPatch || (patch == bestPatch && version > best) {
+			best, bestPatch = version, patch
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no installed ESP-IDF version matches %s (installed: %s)", spec, strings.Join(installed, ", "))
+	}
+	return best, nil
+}
+
+func buildProjectMatrix() error {
+	var targets []string
+	if buildTargets != "" {
+		var err error
+		if targets, err = parseTargetList(buildTargets); err != nil {
+			return err
+		}
+	}
+
+	var versions []string
+	if buildIDF != "" {
+		if idfVersionOverride != "" || idfPathOverride != "" {
+			return fmt.Errorf("--idf cannot be combined with --idf-version or --idf-path")
+		}
+		var err error
+		if versions, err = resolveInstalledVersionList(buildIDF); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := getProjectManifest()
+	if err != nil {
+		return err
+	}
+
+	projectDir := getProjectDir()
+	toolchain, err := toolchainFlag(buildToolchain, useClang)
+	if err != nil {
+		return err
+	}
+	base, err := resolveBuildConfig(manifest, buildProfile, toolchain, projectDir)
+	if err != nil {
+		return err
+	}
+
+	type installation struct{ version, path string }
+	var installations []installation
+	if len(versions) == 0 {
+		version, idfPath, err := resolveProjectIDF()
+		if err != nil {
+			return err
+		}
+		installations = append(installations, installation{version, idfPath})
+	}
+	for _, version := range versions {
+		installations = append(installations, installation{version, filepath.Join(getESPBase(), version)})
+	}
+
+	var builds []MatrixBuild
+	for _, install := range installations {
+		env, envErr := getESPIDFEnvironment(install.path)
+		if envErr != nil {
+			envErr = fmt.Errorf("failed to setup ESP-IDF %s environment: %w", install.version, envErr)
+		} else {
+			env = base.applyEnv(applyManifestEnv(env, manifest))
+		}
+
+		dirVersion := ""
+		if len(versions) > 0 {
+			dirVersion = install.version
+		}
+		for _, target := range targetsOrDefault(targets) {
+			build := MatrixBuild{
+				Version: install.version,
+				IDFPath: install.path,
+				Env:     env,
+				Config:  base.forMatrix(dirVersion, target, projectDir),
+				Err:     envErr,
+			}
+			if build.Err == nil {
+				build.Err = build.Config.Toolchain.checkInstalled(env, toolchainTarget(build.Config.Target, projectDir))
+			}
+			builds = append(builds, build)
+		}
+	}
+
+	if len(versions) > 0 {
+		restore, err := preserveDependenciesLock(projectDir)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	for _, build := range builds {
+		if build.Err != nil {
+			continue
+		}
+		if err := runManifestHookWithLabel(manifest, "pre-build", build.Config.BuildDir, projectDir, build.Env); err != nil {
+			return err
+		}
+	}
+	results, buildErr := runBuildMatrix(builds, projectDir, buildJobs, len(versions) > 0)
+	for i, build := range builds {
+		if results[i].Err != nil {
+			continue
+		}
+		if err := runManifestHookWithLabel(manifest, "post-build", build.Config.BuildDir, projectDir, build.Env); err != nil {
+			return err
+		}
+	}
+	return buildErr
+}
+
+func preserveDependenciesLock(projectDir string) (func(), error) {
+	lockPath := filepath.Join(projectDir, "dependencies.lock")
+	data, err := os.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return func() {
+			os.Remove(lockPath)
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if current, err := os.ReadFile(lockPath); err != nil || !bytes.Equal(current, data) {
+			if err := os.WriteFile(lockPath, data, 0o644); err != nil {
+				fmt.Printf("Warning: Could not restore dependencies.lock: %v\n", err)
+			}
+		}
+	}, nil
+}
+
+func targetsOrDefault(targets []string) []string {
+	if len(targets) == 0 {
+		return []string{""}
+	}
+	return targets
+}
+
+func runBuildMatrix(builds []MatrixBuild, projectDir string, jobs int, compareVersions bool) ([]MatrixBuildResult, error) {
+	if jobs <= 0 || jobs > len(builds) {
+		jobs = len(builds)
+	}
+	if jobs > 1 && usesComponentManager(projectDir) {
+		fmt.Println("Project uses the component manager, building one configuration at a time")
+		jobs = 1
+	}
+
+	fmt.Printf("Building %d configurations with %s (%d at a time)...\n", len(builds), builds[0].Config.description(), jobs)
+
+	var (
+		results = make([]MatrixBuildResult, len(builds))
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, jobs)
+	)
+	for i, build := range builds {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, build MatrixBuild) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runMatrixBuild(build, projectDir)
+		}(i, build)
+	}
+	wg.Wait()
+
+	printMatrixSummary(results, compareVersions)
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d builds failed", failed, len(results))
+	}
+	return results, nil
+}
+
+func runMatrixBuild(build MatrixBuild, projectDir string) (result MatrixBuildResult) {
+	config := build.Config
+	result = MatrixBuildResult{Version: build.Version, Target: config.Target, BuildDir: config.BuildDir}
+	if build.Err != nil {
+		result.Err = build.Err
+		fmt.Printf("[%s] Skipped: %v\n", config.BuildDir, build.Err)
+		return result
+	}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	label := config.BuildDir
+	buildDir := filepath.Join(projectDir, config.BuildDir)
+	if err := os.MkdirAll(buildDir, 0o755); err != nil {
+		result.Err = err
+		return result
+	}
+	logPath := filepath.Join(buildDir, matrixLogFile)
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer logFile.Close()
+
+	fmt.Printf("[%s] Building with ESP-IDF %s\n", label, build.Version)
+
+	cmdArgs := append([]string{filepath.Join(build.IDFPath, "tools", "idf.py")}, config.idfPyArgs()...)
+	cmdArgs = append(cmdArgs, "build")
+	cmd := exec.Command("python3", cmdArgs...)
+	cmd.Dir = projectDir
+	cmd.Env = build.Env
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := cmd.Run(); err != nil {
+		result.Err = fmt.Errorf("build failed: %w", err)
+		fmt.Printf("[%s] Build failed, last lines of %s:\n%s", label, logPath, tailFile(logPath, 20))
+		return result
+	}
+
+	result.AppSize, _ = getAppBinarySize(buildDir)
+	fmt.Printf("[%s] Build successful\n", label)
+	return result
+}
+
+func usesComponentManager(projectDir string) bool {
+	for _, pattern := range []string{"idf_component.yml", "main/idf_component.yml", "components/*/idf_component.yml", "dependencies.lock"} {
+		if matches, _ := filepath.Glob(filepath.Join(projectDir, pattern)); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func getAppBinarySize(buildDir string) (int64, error) {
+	appBin, err := getAppBinaryPath(buildDir)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(appBin)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func getAppBinaryPath(buildDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(buildDir, "project_description.json"))
+	if err != nil {
+		return "", err
+	}
+
+	var description struct {
+		AppBin string `json:"app_bin"`
+	}
+	if err := json.Unmarshal(data, &description); err != nil {
+		return "", err
+	}
+	if description.AppBin == "" {
+		return "", fmt.Errorf("project_description.json has no app_bin")
+	}
+	return filepath.Join(buildDir, description.AppBin), nil
+}
+
+func tailFile(path string, lines int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	all := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return "  " + strings.Join(all, "\n  ") + "\n"
+}
+
+func printMatrixSummary(results []MatrixBuildResult, compareVersions bool) {
+	fmt.Println("\nBuild Summary")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("%-15s %-10s %-6s %10s %8s  %s\n", "IDF", "TARGET", "RESULT", "APP SIZE", "TIME", "BUILD DIR")
+	for _, result := range results {
+		size := "-"
+		if result.Err == nil && result.AppSize > 0 {
+			size = formatBytes(result.AppSize)
+		}
+		fmt.Printf("%-15s %-10s %-6s %10s %8s  %s/\n", result.Version, matrixTarget(result), matrixStatus(result), size, result.Duration.Round(time.Second), result.BuildDir)
+	}
+
+	if !compareVersions {
+		return
+	}
+
+	var versions, targets []string
+	status := make(map[string]string)
+	for _, result := range results {
+		if !containsString(versions, result.Version) {
+			versions = append(versions, result.Version)
+		}
+		if !containsString(targets, matrixTarget(result)) {
+			targets = append(targets, matrixTarget(result))
+		}
+		status[result.Version+"/"+matrixTarget(result)] = matrixStatus(result)
+	}
+
+	fmt.Println("\nCompatibility")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+	header := fmt.Sprintf("%-15s", "IDF")
+	for _, target := range targets {
+		header += fmt.Sprintf(" %-10s", target)
+	}
+	fmt.Println(strings.TrimRight(header, " "))
+	for _, version := range versions {
+		row := fmt.Sprintf("%-15s", version)
+		for _, target := range targets {
+			row += fmt.Sprintf(" %-10s", status[version+"/"+target])
+		}
+		fmt.Println(strings.TrimRight(row, " "))
+	}
+}
+
+func matrixTarget(result MatrixBuildResult) string {
+	if result.Target == "" {
+		return "-"
+	}
+	return result.Target
+}
+
+func matrixStatus(result MatrixBuildResult) string {
+	if result.Err != nil {
+		return "FAIL"
+	}
+	return "pass"
+}
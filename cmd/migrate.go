@@ -0,0 +1,465 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateRollback bool
+
+var migrateFiles = []string{".espidf-version", manifestFile, "sdkconfig", "dependencies.lock"}
+
+type MigrationBackup struct {
+	Project   string    `json:"project"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Files     []string  `json:"files"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type kconfigRename struct {
+	Name     string
+	Inverted bool
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <version>",
+	Short: "Move the project to another ESP-IDF version",
+	Long: `Pin the project to another ESP-IDF version, remove the build directories, regenerate
+sdkconfig (and the sdkconfig.<profile> and sdkconfig.<target> files of profile and target
+builds) with the new version and report the options that were renamed, removed or changed.
+The previous pin, sdkconfig files and dependencies.lock are backed up so the migration can
+be undone with --rollback.`,
+	Example: `  idfmgr migrate v5.2.1
+  idfmgr migrate --rollback`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		if migrateRollback {
+			err = rollbackMigration(args)
+		} else {
+			err = migrateProject(args)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error migrating project: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	ValidArgsFunction: completeFirstArg(completeInstalledVersions),
+}
+
+func init() {
+	migrateCmd.Flags().BoolVar(&migrateRollback, "rollback", false, "Undo the last migration of the project")
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func migrateProject(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("specify the ESP-IDF version to migrate to")
+	}
+	to := args[0]
+
+	root, err := getProjectRoot()
+	if err != nil {
+		return err
+	}
+
+	from, err := readProjectVersionAt(root)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		fmt.Printf("Project is already pinned to %s\n", to)
+		return nil
+	}
+
+	idfPath := filepath.Join(getESPBase(), to)
+	if _, err := os.Stat(idfPath); os.IsNotExist(err) {
+		if err := installMissingVersion(to); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := loadProjectManifest(root)
+	if err != nil {
+		return err
+	}
+	base, err := resolveBuildConfig(manifest, "", "", root)
+	if err != nil {
+		return err
+	}
+	configs := []*BuildConfig{base}
+	variants := findSdkconfigVariants(root, manifest)
+	for _, name := range variants {
+		config, err := sdkconfigVariantConfig(root, manifest, name)
+		if err != nil {
+			return err
+		}
+		configs = append(configs, config)
+	}
+
+	fmt.Printf("Migrating %s from %s to %s...\n", root, from, to)
+
+	backupDir := getMigrationBackupDir(root)
+	backup, err := backupProjectFiles(root, backupDir, from, to, variants)
+	if err != nil {
+		return fmt.Errorf("failed to back up project files: %w", err)
+	}
+
+	if err := writeProjectVersion(root, to); err != nil {
+		return err
+	}
+
+	if err := resetBuildDirs(root); err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		if err := reconfigureProject(root, idfPath, manifest, config); err != nil {
+			fmt.Println("\nUndo the migration with: idfmgr migrate --rollback")
+			return err
+		}
+	}
+
+	renames := loadKconfigRenames(idfPath)
+	for _, config := range configs {
+		name := sdkconfigName(config)
+		if !containsString(backup.Files, name) {
+			fmt.Printf("\nNo %s to compare, skipped the configuration report.\n", name)
+			continue
+		}
+		oldConfig, err := parseSdkconfig(filepath.Join(backupDir, name))
+		if err != nil {
+			return err
+		}
+		newConfig, err := parseSdkconfig(filepath.Join(root, name))
+		if err != nil {
+			return err
+		}
+		printSdkconfigChanges(name, oldConfig, newConfig, renames)
+	}
+
+	if err := registerProject(root); err != nil {
+		fmt.Printf("Warning: Could not update project registry: %v\n", err)
+	}
+
+	fmt.Printf("\nMigrated to %s. Build with: idfmgr build\n", to)
+	fmt.Println("Undo the migration with: idfmgr migrate --rollback")
+	return nil
+}
+
+func rollbackMigration(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("--rollback does not take a version")
+	}
+
+	root, err := getProjectRoot()
+	if err != nil {
+		return err
+	}
+
+	backupDir := getMigrationBackupDir(root)
+	data, err := os.ReadFile(filepath.Join(backupDir, "backup.json"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no migration to roll back for %s", root)
+	}
+	if err != nil {
+		return err
+	}
+
+	var backup MigrationBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf("invalid migration backup: %w", err)
+	}
+
+	manifest, err := loadProjectManifest(root)
+	if err != nil {
+		return err
+	}
+	names := append(append([]string{}, migrateFiles...), findSdkconfigVariants(root, manifest)...)
+	for _, name := range backup.Files {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		if containsString(backup.Files, name) {
+			if err := copyFile(filepath.Join(backupDir, name), path); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	if err := resetBuildDirs(root); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(backupDir); err != nil {
+		fmt.Printf("Warning: Could not remove migration backup: %v\n", err)
+	}
+	if err := registerProject(root); err != nil {
+		fmt.Printf("Warning: Could not update project registry: %v\n", err)
+	}
+
+	fmt.Printf("Rolled back %s from %s to %s\n", root, backup.To, backup.From)
+	return nil
+}
+
+func getMigrationBackupDir(root string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(root)))
+	return filepath.Join(getESPBase(), ".migrate", hex.EncodeToString(sum[:8]))
+}
+
+func backupProjectFiles(root, backupDir, from, to string, variants []string) (*MigrationBackup, error) {
+	if err := os.RemoveAll(backupDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(backupDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	backup := &MigrationBackup{Project: root, From: from, To: to, CreatedAt: time.Now()}
+	for _, name := range append(append([]string{}, migrateFiles...), variants...) {
+		path := filepath.Join(root, name)
+		if !pathExists(path) {
+			continue
+		}
+		if err := copyFile(path, filepath.Join(backupDir, name)); err != nil {
+			return nil, err
+		}
+		backup.Files = append(backup.Files, name)
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, "backup.json"), data, 0o644); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+func resetBuildDirs(root string) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || (name != "build" && !strings.HasPrefix(name, "build-")) {
+			continue
+		}
+		if !pathExists(filepath.Join(root, name, "CMakeCache.txt")) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, name)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+		fmt.Printf("Removed %s/\n", name)
+	}
+	return nil
+}
+
+func findSdkconfigVariants(root string, manifest *ProjectManifest) []string {
+	matches, _ := filepath.Glob(filepath.Join(root, "sdkconfig.*"))
+	var names []string
+	for _, match := range matches {
+		name := filepath.Base(match)
+		if _, err := sdkconfigVariantConfig(root, manifest, name); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sdkconfigVariantConfig(root string, manifest *ProjectManifest, name string) (*BuildConfig, error) {
+	parts := strings.Split(strings.TrimPrefix(name, "sdkconfig."), ".")
+	profile := ""
+	if manifest != nil {
+		if _, ok := manifest.Profiles[parts[0]]; ok {
+			profile, parts = parts[0], parts[1:]
+		}
+	}
+	if len(parts) > 1 || len(parts) == 1 && !containsString(supportedTargets, parts[0]) {
+		return nil, fmt.Errorf("%s is not the sdkconfig of a profile or target build", name)
+	}
+
+	config, err := resolveBuildConfig(manifest, profile, "", root)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 1 {
+		config = config.forMatrix("", parts[0], root)
+	}
+	return config, nil
+}
+
+func sdkconfigName(config *BuildConfig) string {
+	if config.Sdkconfig == "" {
+		return "sdkconfig"
+	}
+	return filepath.Base(config.Sdkconfig)
+}
+
+func reconfigureProject(root, idfPath string, manifest *ProjectManifest, config *BuildConfig) error {
+	env, err := getESPIDFEnvironment(idfPath)
+	if err != nil {
+		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
+	}
+	env = config.applyEnv(applyManifestEnv(env, manifest))
+
+	cmdArgs := append([]string{filepath.Join(idfPath, "tools", "idf.py")}, config.idfPyArgs()...)
+	cmdArgs = append(cmdArgs, "reconfigure")
+
+	fmt.Printf("Regenerating %s...\n", sdkconfigName(config))
+	cmd := exec.Command("python3", cmdArgs...)
+	cmd.Dir = root
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("reconfigure failed: %w", err)
+	}
+	return nil
+}
+
+func parseSdkconfig(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "# Deprecated options for backward compatibility") {
+			break
+		}
+		if name, found := strings.CutPrefix(line, "# "); found {
+			if name, found := strings.CutSuffix(name, " is not set"); found && strings.HasPrefix(name, "CONFIG_") {
+				config[name] = "n"
+			}
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); found && strings.HasPrefix(key, "CONFIG_") {
+			config[key] = value
+		}
+	}
+	return config, scanner.Err()
+}
+
+func loadKconfigRenames(idfPath string) map[string]kconfigRename {
+	renames := make(map[string]kconfigRename)
+
+	filepath.WalkDir(filepath.Join(idfPath, "components"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "sdkconfig.rename" && !strings.HasPrefix(d.Name(), "sdkconfig.rename.") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			inverted := strings.HasPrefix(fields[1], "!")
+			renames[fields[0]] = kconfigRename{Name: strings.TrimPrefix(fields[1], "!"), Inverted: inverted}
+		}
+		return nil
+	})
+
+	return renames
+}
+
+func printSdkconfigChanges(name string, oldConfig, newConfig map[string]string, renames map[string]kconfigRename) {
+	var renamed, removed, changed []string
+	handled := make(map[string]bool)
+
+	keys := make([]string, 0, len(oldConfig))
+	for key := range oldConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldValue := oldConfig[key]
+		newValue, stillPresent := newConfig[key]
+
+		if rename, ok := renames[key]; ok && !stillPresent {
+			handled[rename.Name] = true
+			line := fmt.Sprintf("%s -> %s", key, rename.Name)
+			if rename.Inverted {
+				line += " (inverted)"
+			}
+			if value, ok := newConfig[rename.Name]; ok && value != oldValue && !rename.Inverted {
+				line += fmt.Sprintf(" (%s -> %s)", oldValue, value)
+			}
+			renamed = append(renamed, line)
+			continue
+		}
+
+		switch {
+		case !stillPresent:
+			removed = append(removed, fmt.Sprintf("%s (was %s)", key, oldValue))
+		case newValue != oldValue:
+			changed = append(changed, fmt.Sprintf("%s: %s -> %s", key, oldValue, newValue))
+		}
+	}
+
+	added := 0
+	for key := range newConfig {
+		if _, ok := oldConfig[key]; !ok && !handled[key] {
+			added++
+		}
+	}
+
+	fmt.Printf("\nConfiguration Changes: %s\n", name)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{
+		{"Renamed", renamed},
+		{"Removed", removed},
+		{"Changed value or default", changed},
+	} {
+		fmt.Printf("%s (%d):\n", section.title, len(section.lines))
+		for _, line := range section.lines {
+			fmt.Printf("  - %s\n", line)
+		}
+	}
+	fmt.Printf("New options: %d (review them with: idfmgr exec menuconfig)\n", added)
+}
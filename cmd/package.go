@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const packageManifestFile = "manifest.json"
+
+var (
+	packageToolchain string
+	packageProfile   string
+	packageOutput    string
+)
+
+type PackageManifest struct {
+	Project    string            `json:"project"`
+	Version    string            `json:"version"`
+	IDFVersion string            `json:"idf_version"`
+	Chip       string            `json:"chip"`
+	Profile    string            `json:"profile,omitempty"`
+	Toolchain  string            `json:"toolchain"`
+	FlashMode  string            `json:"flash_mode"`
+	FlashSize  string            `json:"flash_size"`
+	FlashFreq  string            `json:"flash_freq"`
+	Images     []PackageImage    `json:"images"`
+	Merged     PackageImage      `json:"merged"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Files      map[string]string `json:"files"`
+}
+
+type PackageImage struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Offset string `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type flasherArgs struct {
+	WriteFlashArgs []string          `json:"write_flash_args"`
+	FlashSettings  map[string]string `json:"flash_settings"`
+	FlashFiles     map[string]string `json:"flash_files"`
+	ExtraArgs      struct {
+		Chip string `json:"chip"`
+	} `json:"extra_esptool_args"`
+}
+
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Package the build artifacts for release",
+	Long: `Collect the binaries listed in flasher_args.json of a build into a versioned archive,
+together with a merged single image to flash at offset 0x0 and a manifest.json with the
+offsets, chip, ESP-IDF version, git describe of the project and SHA-256 checksums.`,
+	Args: cobra.NoArgs,
+	Example: `  idfmgr package
+  idfmgr package --profile release
+  idfmgr package -o dist/firmware.tar.gz`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := packageBuild(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error packaging build: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	packageCmd.Flags().StringVar(&packageToolchain, "toolchain", "", "Package the build of this toolchain: gcc or clang (default: from idfmgr.toml, or gcc)")
+	packageCmd.Flags().StringVar(&packageProfile, "profile", "", "Package the build of a profile declared in idfmgr.toml")
+	packageCmd.Flags().StringVarP(&packageOutput, "output", "o", "", "Output file (default: dist/<project>-<version>-<chip>.tar.gz)")
+	packageCmd.RegisterFlagCompletionFunc("toolchain", completeToolchains)
+	packageCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	rootCmd.AddCommand(packageCmd)
+}
+
+func packageBuild() error {
+	idfVersion, idfPath, err := resolveProjectIDF()
+	if err != nil {
+		return err
+	}
+
+	manifest, err := getProjectManifest()
+	if err != nil {
+		return err
+	}
+
+	projectDir := getProjectDir()
+	config, err := resolveBuildConfig(manifest, packageProfile, packageToolchain, projectDir)
+	if err != nil {
+		return err
+	}
+
+	buildDir := filepath.Join(projectDir, config.BuildDir)
+	data, err := os.ReadFile(filepath.Join(buildDir, "flasher_args.json"))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s/flasher_args.json not found. Build first with: %s", config.BuildDir, config.buildCommand())
+	}
+	if err != nil {
+		return err
+	}
+
+	var args flasherArgs
+	if err := json.Unmarshal(data, &args); err != nil {
+		return fmt.Errorf("failed to parse flasher_args.json: %w", err)
+	}
+	if len(args.FlashFiles) == 0 {
+		return fmt.Errorf("flasher_args.json lists no files to flash")
+	}
+	if args.ExtraArgs.Chip == "" {
+		args.ExtraArgs.Chip = config.Target
+	}
+
+	project := getPackageProjectName(buildDir, projectDir)
+	version := getProjectGitVersion(projectDir)
+
+	name := fmt.Sprintf("%s-%s-%s", project, version, args.ExtraArgs.Chip)
+	if config.Profile != "" {
+		name += "-" + config.Profile
+	}
+	output := packageOutput
+	if output == "" {
+		output = filepath.Join(projectDir, "dist", name+".tar.gz")
+	}
+
+	env, err := getESPIDFEnvironment(idfPath)
+	if err != nil {
+		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
+	}
+
+	fmt.Printf("Packaging %s/ (%s %s, ESP-IDF %s)...\n", config.BuildDir, project, version, idfVersion)
+
+	stagingPath, err := os.MkdirTemp("", "idfmgr-package-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stagingPath)
+
+	mergedName := project + "-merged.bin"
+	mergedPath := filepath.Join(stagingPath, mergedName)
+	if err := mergeFlashImages(env, buildDir, &args, mergedPath); err != nil {
+		return err
+	}
+
+	packageManifest := PackageManifest{
+		Project:    project,
+		Version:    version,
+		IDFVersion: idfVersion,
+		Chip:       args.ExtraArgs.Chip,
+		Profile:    config.Profile,
+		Toolchain:  config.Toolchain.Name,
+		FlashMode:  args.FlashSettings["flash_mode"],
+		FlashSize:  args.FlashSettings["flash_size"],
+		FlashFreq:  args.FlashSettings["flash_freq"],
+		CreatedAt:  time.Now(),
+		Files:      make(map[string]string),
+	}
+
+	names := getFlasherImageNames(data)
+	size, err := writeArchive(output, func(tw *tar.Writer) error {
+		for _, offset := range sortedFlashOffsets(args.FlashFiles) {
+			relPath := args.FlashFiles[offset]
+			archiveName := path.Join(name, filepath.ToSlash(relPath))
+			if err := addFileToBundle(tw, filepath.Join(buildDir, relPath), archiveName, packageManifest.Files); err != nil {
+				return fmt.Errorf("failed to add %s: %w", relPath, err)
+			}
+
+			image, err := newPackageImage(filepath.Join(buildDir, relPath), relPath, offset)
+			if err != nil {
+				return err
+			}
+			image.Name = names[offset]
+			if image.Name == "" {
+				image.Name = strings.TrimSuffix(filepath.Base(relPath), ".bin")
+			}
+			packageManifest.Images = append(packageManifest.Images, image)
+		}
+
+		if err := addFileToBundle(tw, mergedPath, path.Join(name, mergedName), packageManifest.Files); err != nil {
+			return err
+		}
+		merged, err := newPackageImage(mergedPath, mergedName, "0x0")
+		if err != nil {
+			return err
+		}
+		merged.Name = "merged"
+		packageManifest.Merged = merged
+
+		if err := addBytesToBundle(tw, path.Join(name, "flasher_args.json"), data, packageManifest.Files); err != nil {
+			return err
+		}
+
+		manifestData, err := json.MarshalIndent(packageManifest, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		return addBytesToBundle(tw, path.Join(name, packageManifestFile), manifestData, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%-20s %-10s %10s  %s\n", "IMAGE", "OFFSET", "SIZE", "FILE")
+	for _, image := range append(packageManifest.Images, packageManifest.Merged) {
+		fmt.Printf("%-20s %-10s %10s  %s\n", image.Name, image.Offset, formatBytes(image.Size), image.File)
+	}
+
+	fmt.Printf("\nPackage created: %s (%s)\n", output, formatBytes(size))
+	fmt.Printf("Flash the merged image with: esptool.py --chip %s write_flash 0x0 %s\n", args.ExtraArgs.Chip, mergedName)
+	return nil
+}
+
+func mergeFlashImages(env []string, buildDir string, args *flasherArgs, output string) error {
+	python, err := lookPathInEnv("python", env, "")
+	if err != nil {
+		return err
+	}
+
+	cmdArgs := []string{"-m", "esptool", "--chip", args.ExtraArgs.Chip, "merge_bin", "-o", output}
+	cmdArgs = append(cmdArgs, args.WriteFlashArgs...)
+	for _, offset := range sortedFlashOffsets(args.FlashFiles) {
+		cmdArgs = append(cmdArgs, offset, args.FlashFiles[offset])
+	}
+
+	var log bytes.Buffer
+	cmd := exec.Command(python, cmdArgs...)
+	cmd.Dir = buildDir
+	cmd.Env = env
+	cmd.Stdout = &log
+	cmd.Stderr = &log
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("esptool merge_bin failed: %w\n%s", err, strings.TrimSpace(log.String()))
+	}
+	return nil
+}
+
+func getFlasherImageNames(data []byte) map[string]string {
+	var entries map[string]json.RawMessage
+	json.Unmarshal(data, &entries)
+
+	names := make(map[string]string)
+	for name, raw := range entries {
+		var entry struct {
+			Offset string `json:"offset"`
+			File   string `json:"file"`
+		}
+		if json.Unmarshal(raw, &entry) == nil && entry.Offset != "" && entry.File != "" {
+			names[entry.Offset] = name
+		}
+	}
+	return names
+}
+
+func sortedFlashOffsets(files map[string]string) []string {
+	offsets := make([]string, 0, len(files))
+	for offset := range files {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		a, _ := strconv.ParseUint(offsets[i], 0, 64)
+		b, _ := strconv.ParseUint(offsets[j], 0, 64)
+		return a < b
+	})
+	return offsets
+}
+
+func newPackageImage(filePath, name, offset string) (PackageImage, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return PackageImage{}, err
+	}
+	sum, err := sha256File(filePath)
+	if err != nil {
+		return PackageImage{}, err
+	}
+	return PackageImage{File: filepath.ToSlash(name), Offset: offset, Size: info.Size(), SHA256: sum}, nil
+}
+
+func getPackageProjectName(buildDir, projectDir string) string {
+	var description struct {
+		ProjectName string `json:"project_name"`
+	}
+	if data, err := os.ReadFile(filepath.Join(buildDir, "project_description.json")); err == nil {
+		if json.Unmarshal(data, &description) == nil && description.ProjectName != "" {
+			return description.ProjectName
+		}
+	}
+	if abs, err := filepath.Abs(projectDir); err == nil {
+		return filepath.Base(abs)
+	}
+	return "firmware"
+}
+
+func getProjectGitVersion(projectDir string) string {
+	cmd := exec.Command("git", "describe", "--tags", "--always", "--dirty")
+	cmd.Dir = projectDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [version]",
+	Short: "Show or change the ESP-IDF version pinned by the project",
+	Long: `Show the ESP-IDF version pinned by the current project, or pin another version.
+Updates .espidf-version and idfmgr.toml. Use 'idfmgr migrate' to also reset the build
+directories and regenerate sdkconfig.`,
+	Args: cobra.MaximumNArgs(1),
+	Example: `  idfmgr pin
+  idfmgr pin v5.2.1`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pinProjectVersion(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error pinning version: %v\n", err)
+			os.Exit(1)
+		}
+	},
+	ValidArgsFunction: completeFirstArg(completeInstalledVersions),
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+}
+
+func pinProjectVersion(args []string) error {
+	root, err := getProjectRoot()
+	if err != nil {
+		return err
+	}
+
+	current, pinned, err := lookupProjectVersion(root)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if pinned != "" && pinned != current {
+			fmt.Fprintf(os.Stderr, "Warning: .espidf-version (%s) does not match %s (%s). Sync it with: idfmgr pin %s\n", pinned, manifestFile, current, current)
+		}
+		fmt.Println(current)
+		return nil
+	}
+
+	version := args[0]
+	if version == current && pinned == current {
+		fmt.Printf("Project is already pinned to %s\n", version)
+		return nil
+	}
+
+	if err := writeProjectVersion(root, version); err != nil {
+		return err
+	}
+	if err := registerProject(root); err != nil {
+		fmt.Printf("Warning: Could not update project registry: %v\n", err)
+	}
+
+	if version == current {
+		fmt.Printf("Synced .espidf-version with %s (%s)\n", manifestFile, version)
+		return nil
+	}
+	fmt.Printf("Pinned %s to %s (was %s)\n", root, version, current)
+	if !isValidESPIDFInstall(filepath.Join(getESPBase(), version)) {
+		fmt.Printf("Version %s is not installed. Install it with: idfmgr install %s\n", version, version)
+	}
+	fmt.Println("Tip: Use 'idfmgr migrate' instead to also reset the build directories and regenerate sdkconfig")
+	return nil
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type BuildConfig struct {
+	Profile   string
+	Target    string
+	Toolchain *Toolchain
+	BuildDir  string
+	Sdkconfig string
+	Defaults  []string
+	Env       map[string]string
+}
+
+func resolveBuildConfig(manifest *ProjectManifest, profile, toolchain string, projectDir string) (*BuildConfig, error) {
+	config := &BuildConfig{Profile: profile}
+	if manifest != nil && len(manifest.Targets) > 0 {
+		config.Target = manifest.Targets[0]
+	}
+
+	var settings ManifestProfile
+	if profile != "" {
+		var declared bool
+		if manifest != nil {
+			settings, declared = manifest.Profiles[profile]
+		}
+		if !declared {
+			available := manifestProfileNames(manifest)
+			if len(available) == 0 {
+				return nil, fmt.Errorf("profile %q is not declared, add a [profiles.%s] section to %s", profile, profile, manifestFile)
+			}
+			return nil, fmt.Errorf("profile %q is not declared in %s (available: %s)", profile, manifestFile, strings.Join(available, ", "))
+		}
+	}
+
+	if toolchain == "" {
+		toolchain = settings.Toolchain
+	}
+	if toolchain == "" {
+		toolchain = manifestToolchain(manifest)
+	}
+	var err error
+	if config.Toolchain, err = getToolchain(toolchain); err != nil {
+		return nil, err
+	}
+
+	config.BuildDir = "build"
+	if profile != "" {
+		config.BuildDir += "-" + profile
+	}
+	config.BuildDir += config.Toolchain.DirSuffix
+	if profile == "" {
+		return config, nil
+	}
+
+	config.Sdkconfig = filepath.Join(projectDir, "sdkconfig."+profile)
+	config.Env = settings.Env
+
+	defaults := settings.SdkconfigDefaults
+	if len(defaults) == 0 {
+		for _, name := range []string{"sdkconfig.defaults", "sdkconfig.defaults." + profile} {
+			if pathExists(filepath.Join(projectDir, name)) {
+				defaults = append(defaults, name)
+			}
+		}
+	}
+	for _, name := range defaults {
+		path := filepath.Join(projectDir, name)
+		if !pathExists(path) {
+			return nil, fmt.Errorf("profile %q: %s not found", profile, name)
+		}
+		config.Defaults = append(config.Defaults, path)
+	}
+
+	return config, nil
+}
+
+func (c *BuildConfig) forMatrix(version, target, projectDir string) *BuildConfig {
+	config := *c
+	if target != "" {
+		config.Target = target
+	}
+
+	name := "build"
+	sdkconfig := "sdkconfig"
+	for _, part := range []string{c.Profile, target, version} {
+		if part != "" {
+			name += "-" + part
+			sdkconfig += "." + part
+		}
+	}
+	config.BuildDir = name + c.Toolchain.DirSuffix
+	config.Sdkconfig = filepath.Join(projectDir, sdkconfig)
+
+	if target == "" {
+		return &config
+	}
+
+	// ESP-IDF only picks up sdkconfig.defaults.<target> next to a listed sdkconfig.defaults.
+	baseDefaults := filepath.Join(projectDir, "sdkconfig.defaults")
+	targetDefaults := filepath.Join(projectDir, "sdkconfig.defaults."+target)
+	implicit := len(c.Defaults) == 0 && pathExists(baseDefaults)
+	if !implicit && !containsString(c.Defaults, baseDefaults) && pathExists(targetDefaults) {
+		config.Defaults = append(append([]string(nil), c.Defaults...), targetDefaults)
+	}
+	return &config
+}
+
+func (c *BuildConfig) idfPyArgs() []string {
+	args := append([]string(nil), c.Toolchain.CMakeArgs...)
+	if c.BuildDir != "build" {
+		args = append(args, "-B", c.BuildDir)
+	}
+	if c.Sdkconfig != "" {
+		args = append(args, "-DSDKCONFIG="+c.Sdkconfig)
+	}
+	if len(c.Defaults) > 0 {
+		args = append(args, "-DSDKCONFIG_DEFAULTS="+strings.Join(c.Defaults, ";"))
+	}
+	if c.Target != "" {
+		args = append(args, "-DIDF_TARGET="+c.Target)
+	}
+	return args
+}
+
+func (c *BuildConfig) applyEnv(env []string) []string {
+	if len(c.Env) == 0 {
+		return env
+	}
+	vars := envListToMap(env)
+	for key, value := range c.Env {
+		vars[key] = os.ExpandEnv(value)
+	}
+	return envMapToList(vars)
+}
+
+func (c *BuildConfig) description() string {
+	if c.Profile == "" {
+		return c.Toolchain.Label + " toolchain"
+	}
+	return fmt.Sprintf("%s toolchain, profile %s", c.Toolchain.Label, c.Profile)
+}
+
+func (c *BuildConfig) buildCommand() string {
+	command := "idfmgr build"
+	if c.Profile != "" {
+		command += " --profile " + c.Profile
+	}
+	if c.Toolchain.Name != defaultToolchain {
+		command += " --toolchain " + c.Toolchain.Name
+	}
+	return command
+}
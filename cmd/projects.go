@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type ProjectRecord struct {
+	Path    string    `json:"path"`
+	Version string    `json:"version"`
+	Target  string    `json:"target,omitempty"`
+	Added   time.Time `json:"added"`
+}
+
+type ProjectRegistry struct {
+	Projects []ProjectRecord `json:"projects"`
+}
+
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "List registered ESP-IDF projects",
+	Long: `Show every registered project with the ESP-IDF version it is pinned to, its target
+and whether the pinned version is installed. Projects created with idfmgr are
+registered automatically, existing ones can be added with 'idfmgr projects scan'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listProjects(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing projects: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var projectsScanCmd = &cobra.Command{
+	Use:   "scan <dir>",
+	Short: "Register every ESP-IDF project found under a directory",
+	Args:  cobra.ExactArgs(1),
+	Example: `  idfmgr projects scan ~/src
+  idfmgr projects scan .`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := scanProjects(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error scanning projects: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var projectsForgetCmd = &cobra.Command{
+	Use:   "forget <path...>",
+	Short: "Remove projects from the registry",
+	Long:  `Remove projects from the registry. The project files are left untouched.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := forgetProjects(args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing projects: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	projectsCmd.AddCommand(projectsScanCmd)
+	projectsCmd.AddCommand(projectsForgetCmd)
+	rootCmd.AddCommand(projectsCmd)
+}
+
+func listProjects() error {
+	registry, err := loadProjectRegistry()
+	if err != nil {
+		return err
+	}
+
+	if len(registry.Projects) == 0 {
+		fmt.Println("No projects registered.")
+		fmt.Println("Register existing projects with: idfmgr projects scan <dir>")
+		return nil
+	}
+
+	if refreshProjectRecords(registry) {
+		if err := saveProjectRegistry(registry); err != nil {
+			fmt.Printf("Warning: Could not update project registry: %v\n", err)
+		}
+	}
+
+	espBase := getESPBase()
+
+	fmt.Printf("%-15s %-10s %-15s %s\n", "VERSION", "TARGET", "STATUS", "PATH")
+	fmt.Printf("%s\n", strings.Repeat("-", 70))
+
+	for _, project := range registry.Projects {
+		status := "installed"
+		if !pathExists(project.Path) {
+			status = "missing"
+		} else if !isValidESPIDFInstall(filepath.Join(espBase, project.Version)) {
+			status = "not installed"
+		}
+
+		target := project.Target
+		if target == "" {
+			target = "-"
+		}
+
+		fmt.Printf("%-15s %-10s %-15s %s\n", project.Version, target, status, project.Path)
+	}
+
+	fmt.Printf("\nTotal: %d project(s) registered\n", len(registry.Projects))
+	return nil
+}
+
+func scanProjects(dir string) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	registry, err := loadProjectRegistry()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Scanning %s for ESP-IDF projects...\n", root)
+
+	found := 0
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			switch d.Name() {
+			case ".git", "build", "managed_components", "node_modules":
+				return filepath.SkipDir
+			}
+			if strings.HasPrefix(d.Name(), "build-") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Name() != ".espidf-version" && d.Name() != manifestFile {
+			return nil
+		}
+
+		record, err := readProjectRecord(filepath.Dir(path))
+		if err != nil {
+			fmt.Printf("Warning: Skipping %s: %v\n", filepath.Dir(path), err)
+			return nil
+		}
+
+		if registry.add(record) {
+			fmt.Printf("  + %s (%s)\n", record.Path, record.Version)
+			found++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+
+	if found == 0 {
+		fmt.Println("No new projects found.")
+		return nil
+	}
+
+	if err := saveProjectRegistry(registry); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nRegistered %d new project(s)\n", found)
+	return nil
+}
+
+func forgetProjects(paths []string) error {
+	registry, err := loadProjectRegistry()
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+
+		if registry.remove(absPath) {
+			fmt.Printf("  - %s\n", absPath)
+			removed++
+		} else {
+			fmt.Printf("Warning: %s is not registered, skipping\n", absPath)
+		}
+	}
+
+	if removed == 0 {
+		return nil
+	}
+
+	return saveProjectRegistry(registry)
+}
+
+func registerProject(projectPath string) error {
+	absPath, err := filepath.Abs(projectPath)
+	if err != nil {
+		return err
+	}
+
+	record, err := readProjectRecord(absPath)
+	if err != nil {
+		return err
+	}
+
+	registry, err := loadProjectRegistry()
+	if err != nil {
+		return err
+	}
+
+	registry.add(record)
+	return saveProjectRegistry(registry)
+}
+
+func projectsByVersion() map[string][]ProjectRecord {
+	byVersion := make(map[string][]ProjectRecord)
+
+	registry, err := loadProjectRegistry()
+	if err != nil {
+		return byVersion
+	}
+	refreshProjectRecords(registry)
+
+	for _, project := range registry.Projects {
+		if pathExists(project.Path) {
+			byVersion[project.Version] = append(byVersion[project.Version], project)
+		}
+	}
+	return byVersion
+}
+
+func readProjectRecord(projectPath string) (ProjectRecord, error) {
+	version, _, err := lookupProjectVersion(projectPath)
+	if err != nil {
+		return ProjectRecord{}, err
+	}
+
+	return ProjectRecord{
+		Path:    projectPath,
+		Version: version,
+		Target:  readProjectTarget(projectPath),
+		Added:   time.Now(),
+	}, nil
+}
+
+func readProjectTarget(projectPath string) string {
+	for _, name := range []string{"sdkconfig", "sdkconfig.defaults"} {
+		file, err := os.Open(filepath.Join(projectPath, name))
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if value, found := strings.CutPrefix(line, "CONFIG_IDF_TARGET="); found {
+				file.Close()
+				return strings.Trim(value, `"`)
+			}
+		}
+		file.Close()
+	}
+	return ""
+}
+
+func refreshProjectRecords(registry *ProjectRegistry) bool {
+	changed := false
+	for i, project := range registry.Projects {
+		record, err := readProjectRecord(project.Path)
+		if err != nil {
+			continue
+		}
+		if record.Version != project.Version || record.Target != project.Target {
+			registry.Projects[i].Version = record.Version
+			registry.Projects[i].Target = record.Target
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (r *ProjectRegistry) add(record ProjectRecord) bool {
+	for i, project := range r.Projects {
+		if project.Path == record.Path {
+			r.Projects[i].Version = record.Version
+			r.Projects[i].Target = record.Target
+			return false
+		}
+	}
+
+	r.Projects = append(r.Projects, record)
+	sort.Slice(r.Projects, func(i, j int) bool {
+		return r.Projects[i].Path < r.Projects[j].Path
+	})
+	return true
+}
+
+func (r *ProjectRegistry) remove(path string) bool {
+	for i, project := range r.Projects {
+		if project.Path == path {
+			r.Projects = append(r.Projects[:i], r.Projects[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func getProjectRegistryPath() string {
+	return filepath.Join(getESPBase(), "projects.json")
+}
+
+func loadProjectRegistry() (*ProjectRegistry, error) {
+	registry := &ProjectRegistry{}
+
+	data, err := os.ReadFile(getProjectRegistryPath())
+	if os.IsNotExist(err) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project registry: %w", err)
+	}
+
+	if err := json.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("failed to parse project registry: %w", err)
+	}
+
+	return registry, nil
+}
+
+func saveProjectRegistry(registry *ProjectRegistry) error {
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode project registry: %w", err)
+	}
+
+	if err := writeFileAtomic(getProjectRegistryPath(), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write project registry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	keepPatches int
+	unusedDays  int
+	keepPinned  bool
+	pruneDryRun bool
+	pruneForce  bool
+	prunePurge  bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove installed ESP-IDF versions according to retention policies",
+	Long: `Remove installed ESP-IDF versions that fall outside the given retention policies.
+A version is removed when it violates any of the enabled policies. Versions pinned by
+a registered project are never removed unless --keep-pinned=false is given.`,
+	Args: cobra.NoArgs,
+	Example: `  idfmgr prune --keep-patches 2 --dry-run
+  idfmgr prune --unused-days 90
+  idfmgr prune --keep-patches 1 --unused-days 180 --force`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pruneVersions(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error pruning versions: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	pruneCmd.Flags().IntVar(&keepPatches, "keep-patches", 0, "Keep the newest N patch releases of each minor series")
+	pruneCmd.Flags().IntVar(&unusedDays, "unused-days", 0, "Remove versions not used in the last N days")
+	pruneCmd.Flags().BoolVar(&keepPinned, "keep-pinned", true, "Never remove versions pinned by a registered project")
+	pruneCmd.Flags().BoolVarP(&pruneDryRun, "dry-run", "n", false, "Show what would be removed without removing anything")
+	pruneCmd.Flags().BoolVarP(&pruneForce, "force", "f", false, "Skip confirmation prompts")
+	pruneCmd.Flags().BoolVar(&prunePurge, "purge", false, "Delete permanently instead of moving to the trash")
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func pruneVersions() error {
+	if keepPatches <= 0 && unusedDays <= 0 {
+		return fmt.Errorf("specify at least one policy: --keep-patches or --unused-days")
+	}
+
+	espBase := getESPBase()
+	if _, err := os.Stat(espBase); os.IsNotExist(err) {
+		fmt.Printf("ESP_BASE directory doesn't exist: %s\n", espBase)
+		return nil
+	}
+
+	installed, err := getInstalledVersions(espBase)
+	if err != nil {
+		return fmt.Errorf("failed to get installed versions: %w", err)
+	}
+
+	reasons := make(map[string][]string)
+
+	if keepPatches > 0 {
+		for _, version := range versionsBeyondPatchLimit(installed, keepPatches) {
+			reasons[version] = append(reasons[version], fmt.Sprintf("not among newest %d patch(es) of its series", keepPatches))
+		}
+	}
+
+	if unusedDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -unusedDays)
+		usage := loadVersionUsage()
+		var untracked []string
+		for _, version := range installed {
+			lastUsed, ok := usage[version]
+			if !ok {
+				untracked = append(untracked, version)
+				continue
+			}
+			if lastUsed.Before(cutoff) {
+				reasons[version] = append(reasons[version], fmt.Sprintf("last used %s", lastUsed.Format("2006-01-02")))
+			}
+		}
+
+		if len(untracked) > 0 {
+			fmt.Printf("No usage recorded yet for %s, skipping the --unused-days policy for them.\n", strings.Join(untracked, ", "))
+			if !pruneDryRun {
+				if err := seedVersionUsage(untracked); err != nil {
+					fmt.Printf("Warning: Could not update %s: %v\n", getUsagePath(), err)
+				} else {
+					fmt.Println("Their usage is tracked from now on.")
+				}
+			}
+			fmt.Println()
+		}
+	}
+
+	byVersion := projectsByVersion()
+	var toRemove []string
+	var kept []string
+
+	for _, version := range installed {
+		if len(reasons[version]) == 0 {
+			continue
+		}
+		if keepPinned && len(byVersion[version]) > 0 {
+			kept = append(kept, version)
+			continue
+		}
+		toRemove = append(toRemove, version)
+	}
+
+	if len(kept) > 0 {
+		fmt.Printf("Keeping %d version(s) pinned by registered projects:\n", len(kept))
+		for _, version := range kept {
+			fmt.Printf("  - %s (%d project(s))\n", version, len(byVersion[version]))
+		}
+		fmt.Println()
+	}
+
+	if len(toRemove) == 0 {
+		fmt.Println("Nothing to prune.")
+		return nil
+	}
+
+	fmt.Printf("Will remove %d version(s):\n", len(toRemove))
+	for _, version := range toRemove {
+		fmt.Printf("  - %-15s %s\n", version, strings.Join(reasons[version], ", "))
+	}
+
+	totalSize, err := calculateTotalSize(espBase, toRemove)
+	if err != nil {
+		fmt.Printf("Warning: Could not calculate disk space: %v\n", err)
+	} else {
+		fmt.Printf("\nTotal disk space to be freed: %s\n", formatBytes(totalSize))
+		if !prunePurge {
+			fmt.Println("(once the trash is emptied, use --purge to delete immediately)")
+		}
+	}
+
+	if pruneDryRun {
+		fmt.Println("\nDry run, nothing was removed.")
+		return nil
+	}
+
+	if !pruneForce {
+		confirmed, err := confirm("\nAre you sure you want to proceed?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Operation cancelled.")
+			return nil
+		}
+	}
+
+	deleteVersions(espBase, toRemove, prunePurge, pruneForce)
+	return nil
+}
+
+func versionsBeyondPatchLimit(versions []string, keep int) []string {
+	type parsedVersion struct {
+		name  string
+		patch int
+	}
+
+	series := make(map[string][]parsedVersion)
+	for _, version := range versions {
+		major, minor, patch, ok := parseIDFVersion(version)
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%d.%d", major, minor)
+		series[key] = append(series[key], parsedVersion{name: version, patch: patch})
+	}
+
+	var result []string
+	for _, members := range series {
+		sort.Slice(members, func(i, j int) bool {
+			if members[i].patch != members[j].patch {
+				return members[i].patch > members[j].patch
+			}
+			return members[i].name > members[j].name
+		})
+		for i := keep; i < len(members); i++ {
+			result = append(result, members[i].name)
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func getUsagePath() string {
+	return filepath.Join(getESPBase(), "usage.json")
+}
+
+func loadVersionUsage() map[string]time.Time {
+	usage := make(map[string]time.Time)
+
+	data, err := os.ReadFile(getUsagePath())
+	if err != nil {
+		return usage
+	}
+
+	json.Unmarshal(data, &usage)
+	return usage
+}
+
+func recordVersionUse(idfPath string) {
+	espBase := getESPBase()
+	if filepath.Clean(filepath.Dir(idfPath)) != filepath.Clean(espBase) {
+		return
+	}
+
+	usage := loadVersionUsage()
+	version := filepath.Base(idfPath)
+	if time.Since(usage[version]) < time.Hour {
+		return
+	}
+	usage[version] = time.Now()
+
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return
+	}
+	writeFileAtomic(getUsagePath(), data, 0o644)
+}
+
+func seedVersionUsage(versions []string) error {
+	usage := loadVersionUsage()
+	for _, version := range versions {
+		if _, ok := usage[version]; !ok {
+			usage[version] = time.Now()
+		}
+	}
+
+	data, err := json.MarshalIndent(usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(getUsagePath(), data, 0o644)
+}
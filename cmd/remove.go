@@ -3,37 +3,46 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	removeAll bool
-	force     bool
+	removeAll   bool
+	force       bool
+	removePurge bool
 )
 
 var removeCmd = &cobra.Command{
 	Use:   "remove [version...]",
 	Short: "Remove installed ESP-IDF versions",
 	Long: `Remove one or more installed ESP-IDF versions from the ESP_BASE directory.
-Use all to remove all installed versions.`,
+Use all to remove all installed versions. Removed versions are moved to the trash
+inside ESP_BASE and can be brought back with 'idfmgr restore <version>'.`,
 	Example: `  idfmgr remove v5.1.2
   idfmgr remove v4.4.6 v5.0.0
   idfmgr remove all
-  idfmgr remove v5.1.2 --force`,
+  idfmgr remove v5.1.2 --force
+  idfmgr remove v5.1.2 --purge`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := removeVersions(args); err != nil {
 			fmt.Fprintf(os.Stderr, "Error removing versions: %v\n", err)
 			os.Exit(1)
 		}
 	},
+	ValidArgsFunction: completeRemovableVersions,
 }
 
 func init() {
 	removeCmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompts")
+	removeCmd.Flags().BoolVar(&removePurge, "purge", false, "Delete permanently instead of moving to the trash")
 	rootCmd.AddCommand(removeCmd)
 }
 
@@ -98,35 +107,64 @@ func removeVersions(versions []string) error {
 		}
 	}
 
+	byVersion := projectsByVersion()
+	var inUse bool
+	for _, version := range toRemove {
+		projects := byVersion[version]
+		if len(projects) == 0 {
+			continue
+		}
+		if !inUse {
+			fmt.Printf("\nWarning: The following registered projects depend on these versions:\n")
+			inUse = true
+		}
+		for _, project := range projects {
+			fmt.Printf("  %-15s %s\n", version, project.Path)
+		}
+	}
+
 	totalSize, err := calculateTotalSize(espBase, toRemove)
 	if err != nil {
 		fmt.Printf("Warning: Could not calculate disk space: %v\n", err)
 	} else {
 		fmt.Printf("\nTotal disk space to be freed: %s\n", formatBytes(totalSize))
+		if !removePurge {
+			fmt.Println("(once the trash is emptied, use --purge to delete immediately)")
+		}
 	}
 
 	if !force {
-		fmt.Print("\nAre you sure you want to proceed? [y/N]: ")
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
+		confirmed, err := confirm("\nAre you sure you want to proceed?")
 		if err != nil {
-			return fmt.Errorf("failed to read input: %w", err)
+			return err
 		}
-
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
+		if !confirmed {
 			fmt.Println("Operation cancelled.")
 			return nil
 		}
 	}
 
+	deleteVersions(espBase, toRemove, removePurge, force)
+	return nil
+}
+
+func deleteVersions(espBase string, toRemove []string, purge, force bool) []string {
 	var removed []string
 	var failed []string
+	var trashed []string
 
 	for _, version := range toRemove {
-		versionPath := filepath.Join(espBase, version)
+		var err error
+		if purge {
+			err = os.RemoveAll(filepath.Join(espBase, version))
+		} else {
+			var trashPath string
+			if trashPath, err = moveToTrash(espBase, version); err == nil {
+				trashed = append(trashed, trashPath)
+			}
+		}
 
-		if err := os.RemoveAll(versionPath); err != nil {
+		if err != nil {
 			fmt.Printf("Failed to remove %s: %v\n", version, err)
 			failed = append(failed, version)
 		} else {
@@ -146,7 +184,30 @@ func removeVersions(versions []string) error {
 		}
 	}
 
-	return nil
+	if !purge && len(removed) > 0 {
+		fmt.Println("\nRemoved versions were moved to the trash.")
+		fmt.Println("Restore with: idfmgr restore <version>")
+		fmt.Println("Free the space with: idfmgr trash empty")
+		enforceTrashLimits(force, trashed)
+	}
+
+	return removed
+}
+
+func confirm(prompt string) (bool, error) {
+	return confirmOn(os.Stdout, prompt)
+}
+
+func confirmOn(out io.Writer, prompt string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", prompt)
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes", nil
 }
 
 func getInstalledVersions(espBase string) ([]string, error) {
@@ -184,19 +245,63 @@ func calculateTotalSize(espBase string, versions []string) (int64, error) {
 }
 
 func getDirSize(path string) (int64, error) {
-	var size int64
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+
+	var (
+		size     int64
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		sem      = make(chan struct{}, runtime.NumCPU()*4)
+	)
+
+	setErr := func(err error) {
+		errOnce.Do(func() { firstErr = err })
+	}
 
-	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+	var walk func(dir string)
+	walk = func(dir string) {
+		defer wg.Done()
+
+		sem <- struct{}{}
+		defer func() { <-sem }()
+
+		entries, err := os.ReadDir(dir)
 		if err != nil {
-			return err
+			setErr(err)
+			return
 		}
-		if !info.IsDir() {
-			size += info.Size()
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				wg.Add(1)
+				go walk(filepath.Join(dir, entry.Name()))
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				setErr(err)
+				return
+			}
+			atomic.AddInt64(&size, info.Size())
 		}
-		return nil
-	})
+	}
+
+	wg.Add(1)
+	go walk(path)
+	wg.Wait()
 
-	return size, err
+	return size, firstErr
 }
 
 func formatBytes(bytes int64) string {
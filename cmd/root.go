@@ -1,15 +1,31 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	idfPathOverride    string
+	idfVersionOverride string
+	workDir            string
+	autoInstall        bool
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "idfmgr",
 	Short: "Manage ESP-IDF installations, versions, and projects",
 	Long:  `idfmgr simplifies ESP32 development by managing multiple ESP-IDF versions, creating projects with templates, and supporting both GCC and Clang toolchain.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if workDir != "" {
+			if err := os.Chdir(workDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error changing directory: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
 }
 
 func Execute() {
@@ -20,5 +36,11 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVarP(&workDir, "directory", "C", "", "Run as if idfmgr was started in this directory")
+	rootCmd.PersistentFlags().StringVar(&idfPathOverride, "idf-path", "", "Use the ESP-IDF at this path instead of the project's .espidf-version")
+	rootCmd.PersistentFlags().StringVar(&idfVersionOverride, "idf-version", "", "Use this installed ESP-IDF version instead of the project's .espidf-version (or set IDFMGR_VERSION)")
+	rootCmd.PersistentFlags().BoolVar(&autoInstall, "auto-install", false, "Install the requested ESP-IDF version without asking if it is missing (or set IDFMGR_AUTO_INSTALL=1)")
+	rootCmd.MarkFlagsMutuallyExclusive("idf-path", "idf-version")
+	rootCmd.RegisterFlagCompletionFunc("idf-version", completeInstalledVersions)
 }
 
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	shellPrompt bool
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Start a subshell with the project's ESP-IDF environment",
+	Long: `Start your $SHELL with the ESP-IDF environment of the version pinned in .espidf-version.
+The subshell exports IDFMGR_ACTIVE and IDFMGR_SHELL_VERSION and prefixes the prompt with the
+active version. Exit the subshell to return to your previous environment.`,
+	Args: cobra.NoArgs,
+	Example: `  idfmgr shell
+  idfmgr shell --prompt=false`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runProjectShell(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			fmt.Fprintf(os.Stderr, "Error starting shell: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	shellCmd.Flags().BoolVar(&shellPrompt, "prompt", true, "Prefix the shell prompt with the active ESP-IDF version")
+	rootCmd.AddCommand(shellCmd)
+}
+
+func runProjectShell() error {
+	if active := os.Getenv("IDFMGR_ACTIVE"); active != "" {
+		return fmt.Errorf("already inside an idfmgr shell for %s, exit it first", os.Getenv("IDFMGR_SHELL_VERSION"))
+	}
+
+	version, idfPath, err := resolveProjectIDF()
+	if err != nil {
+		return err
+	}
+
+	env, err := getESPIDFEnvironment(idfPath)
+	if err != nil {
+		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
+	}
+	env = append(env, "IDFMGR_ACTIVE=1", "IDFMGR_SHELL_VERSION="+version)
+
+	shellPath := getUserShell()
+	shellArgs := []string{}
+
+	if shellPrompt {
+		tmpDir, err := os.MkdirTemp("", "idfmgr-shell-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+
+		marker := fmt.Sprintf("(idf %s) ", version)
+		promptArgs, promptEnv, err := shellPromptSetup(shellPath, marker, tmpDir)
+		if err != nil {
+			return err
+		}
+		shellArgs = append(shellArgs, promptArgs...)
+		env = append(env, promptEnv...)
+	}
+
+	fmt.Fprintf(os.Stderr, "Entering ESP-IDF %s shell (%s). Type 'exit' to leave.\n", version, shellPath)
+
+	cmd := exec.Command(shellPath, shellArgs...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	fmt.Fprintf(os.Stderr, "Left ESP-IDF %s shell.\n", version)
+	return err
+}
+
+func getUserShell() string {
+	if runtime.GOOS == "windows" {
+		if commandExists("pwsh") {
+			return "pwsh"
+		}
+		if os.Getenv("PSModulePath") != "" {
+			return "powershell.exe"
+		}
+		if comspec := os.Getenv("COMSPEC"); comspec != "" {
+			return comspec
+		}
+		return "cmd.exe"
+	}
+
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+func shellPromptSetup(shellPath, marker, tmpDir string) ([]string, []string, error) {
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(shellPath)), ".exe")
+
+	switch name {
+	case "bash":
+		rcFile := filepath.Join(tmpDir, "bashrc")
+		rc := fmt.Sprintf("[ -f ~/.bashrc ] && . ~/.bashrc\nPS1=%s\"$PS1\"\n", posixQuote(marker))
+		if err := os.WriteFile(rcFile, []byte(rc), 0o644); err != nil {
+			return nil, nil, fmt.Errorf("failed to write shell rc file: %w", err)
+		}
+		return []string{"--rcfile", rcFile}, nil, nil
+	case "zsh":
+		zdotdir := os.Getenv("ZDOTDIR")
+		if zdotdir == "" {
+			zdotdir, _ = os.UserHomeDir()
+		}
+		env := fmt.Sprintf("ZDOTDIR=%s\n[ -f \"$ZDOTDIR/.zshenv\" ] && . \"$ZDOTDIR/.zshenv\"\n_idfmgr_zdotdir=$ZDOTDIR\nZDOTDIR=%s\n", posixQuote(zdotdir), posixQuote(tmpDir))
+		if err := os.WriteFile(filepath.Join(tmpDir, ".zshenv"), []byte(env), 0o644); err != nil {
+			return nil, nil, fmt.Errorf("failed to write shell rc file: %w", err)
+		}
+		rc := fmt.Sprintf("ZDOTDIR=$_idfmgr_zdotdir\nunset _idfmgr_zdotdir\n[ -f \"$ZDOTDIR/.zshrc\" ] && . \"$ZDOTDIR/.zshrc\"\nPROMPT=%s\"$PROMPT\"\n", posixQuote(marker))
+		if err := os.WriteFile(filepath.Join(tmpDir, ".zshrc"), []byte(rc), 0o644); err != nil {
+			return nil, nil, fmt.Errorf("failed to write shell rc file: %w", err)
+		}
+		return nil, []string{"ZDOTDIR=" + tmpDir}, nil
+	case "fish":
+		init := fmt.Sprintf("functions -c fish_prompt _idfmgr_fish_prompt; function fish_prompt; echo -n %s; _idfmgr_fish_prompt; end", fishQuote(marker))
+		return []string{"--init-command", init}, nil, nil
+	case "pwsh", "powershell":
+		init := fmt.Sprintf("$function:_idfmgr_prompt = $function:prompt; function global:prompt { %s + (& $function:_idfmgr_prompt) }", powershellQuote(marker))
+		return []string{"-NoExit", "-Command", init}, nil, nil
+	case "cmd":
+		prompt := os.Getenv("PROMPT")
+		if prompt == "" {
+			prompt = "$P$G"
+		}
+		return []string{"/K"}, []string{"PROMPT=" + marker + prompt}, nil
+	default:
+		return nil, []string{"PS1=" + marker + os.Getenv("PS1")}, nil
+	}
+}
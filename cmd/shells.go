@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var supportedShells = []string{"bash", "zsh", "fish", "nu", "powershell", "cmd"}
+
+var supportedEnvFormats = []string{"dotenv", "json"}
+
+func detectShell() string {
+	if runtime.GOOS == "windows" {
+		if os.Getenv("PSModulePath") != "" {
+			return "powershell"
+		}
+		return "cmd"
+	}
+
+	name := strings.TrimSuffix(filepath.Base(os.Getenv("SHELL")), ".exe")
+	switch name {
+	case "pwsh", "powershell":
+		return "powershell"
+	case "sh", "dash", "ksh":
+		return "bash"
+	}
+	if containsString(supportedShells, name) {
+		return name
+	}
+	return "bash"
+}
+
+func resolveShell(shell string) (string, error) {
+	if shell == "" {
+		return detectShell(), nil
+	}
+	if shell == "pwsh" {
+		return "powershell", nil
+	}
+	if shell == "sh" {
+		return "bash", nil
+	}
+	if !containsString(supportedShells, shell) {
+		return "", fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(supportedShells, ", "))
+	}
+	return shell, nil
+}
+
+func renderShellChanges(shell string, changes []envChange) string {
+	var b strings.Builder
+	for _, change := range changes {
+		b.WriteString(renderShellChange(shell, change))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func renderShellChange(shell string, change envChange) string {
+	sep := string(os.PathListSeparator)
+
+	switch shell {
+	case "fish":
+		switch {
+		case change.Unset:
+			return fmt.Sprintf("set -e %s", change.Key)
+		case change.Prepend != nil:
+			quoted := make([]string, len(change.Prepend))
+			for i, entry := range change.Prepend {
+				quoted[i] = fishQuote(entry)
+			}
+			return fmt.Sprintf("set -gx %s %s $%s", change.Key, strings.Join(quoted, " "), change.Key)
+		case change.Key == "PATH":
+			entries := filepath.SplitList(change.Value)
+			quoted := make([]string, len(entries))
+			for i, entry := range entries {
+				quoted[i] = fishQuote(entry)
+			}
+			return fmt.Sprintf("set -gx %s %s", change.Key, strings.Join(quoted, " "))
+		default:
+			return fmt.Sprintf("set -gx %s %s", change.Key, fishQuote(change.Value))
+		}
+	case "nu":
+		switch {
+		case change.Unset:
+			return fmt.Sprintf("hide-env %s", change.Key)
+		case change.Prepend != nil:
+			quoted := make([]string, len(change.Prepend))
+			for i, entry := range change.Prepend {
+				quoted[i] = nuQuote(entry)
+			}
+			return fmt.Sprintf("$env.%s = ($env.%s | split row (char esep) | prepend [%s])", change.Key, change.Key, strings.Join(quoted, " "))
+		case change.Key == "PATH":
+			return fmt.Sprintf("$env.%s = (%s | split row (char esep))", change.Key, nuQuote(change.Value))
+		default:
+			return fmt.Sprintf("$env.%s = %s", change.Key, nuQuote(change.Value))
+		}
+	case "powershell":
+		switch {
+		case change.Unset:
+			return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue", change.Key)
+		case change.Prepend != nil:
+			return fmt.Sprintf("$env:%s = %s + $env:%s", change.Key, powershellQuote(strings.Join(change.Prepend, sep)+sep), change.Key)
+		default:
+			return fmt.Sprintf("$env:%s = %s", change.Key, powershellQuote(change.Value))
+		}
+	case "cmd":
+		switch {
+		case change.Unset:
+			return fmt.Sprintf(`set "%s="`, change.Key)
+		case change.Prepend != nil:
+			return fmt.Sprintf(`set "%s=%s%s%%%s%%"`, change.Key, strings.Join(change.Prepend, sep), sep, change.Key)
+		default:
+			return fmt.Sprintf(`set "%s=%s"`, change.Key, change.Value)
+		}
+	default:
+		switch {
+		case change.Unset:
+			return fmt.Sprintf("unset %s", change.Key)
+		case change.Prepend != nil:
+			return fmt.Sprintf("export %s=%s\"$%s\"", change.Key, posixQuote(strings.Join(change.Prepend, sep)+sep), change.Key)
+		default:
+			return fmt.Sprintf("export %s=%s", change.Key, posixQuote(change.Value))
+		}
+	}
+}
+
+func renderShellComment(shell, comment string) string {
+	if shell == "cmd" {
+		return "REM " + comment
+	}
+	return "# " + comment
+}
+
+func shellActivateUsage(shell string) string {
+	switch shell {
+	case "fish":
+		return "idfmgr activate --shell fish | source"
+	case "nu":
+		return "load-env (idfmgr activate --format json | from json)"
+	case "powershell":
+		return "idfmgr activate --shell powershell | Out-String | Invoke-Expression"
+	case "cmd":
+		return `for /f "delims=" %i in ('idfmgr activate --shell cmd') do %i`
+	default:
+		return `eval "$(idfmgr activate)"`
+	}
+}
+
+func shellDeactivateUsage(shell string) string {
+	switch shell {
+	case "fish":
+		return "idfmgr deactivate --shell fish | source"
+	case "nu":
+		return "idfmgr deactivate --shell nu | save -f ~/.idfmgr-deactivate.nu, then: source ~/.idfmgr-deactivate.nu"
+	case "powershell":
+		return "idfmgr deactivate --shell powershell | Out-String | Invoke-Expression"
+	case "cmd":
+		return `for /f "delims=" %i in ('idfmgr deactivate --shell cmd') do %i`
+	default:
+		return `eval "$(idfmgr deactivate)"`
+	}
+}
+
+func renderEnvFormat(format string, vars map[string]string) (string, error) {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	switch format {
+	case "dotenv":
+		var b strings.Builder
+		for _, key := range keys {
+			if strings.ContainsAny(vars[key], "\r\n") {
+				return "", fmt.Errorf("%s contains a newline, which dotenv files cannot hold; use --format json", key)
+			}
+			fmt.Fprintf(&b, "%s=%s\n", key, vars[key])
+		}
+		return b.String(), nil
+	case "json":
+		data, err := json.MarshalIndent(vars, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	default:
+		return "", fmt.Errorf("unsupported format %q (supported: %s)", format, strings.Join(supportedEnvFormats, ", "))
+	}
+}
+
+func posixQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, `'`, `'\''`) + "'"
+}
+
+func fishQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, `'`, `\'`) + "'"
+}
+
+func nuQuote(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func powershellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, `'`, `''`) + "'"
+}
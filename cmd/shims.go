@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var shimsCmd = &cobra.Command{
+	Use:   "shims",
+	Short: "List the installed tool shims",
+	Long: `Shims are small executables for idf.py, esptool.py and the toolchain binaries. When run,
+a shim finds the nearest .espidf-version, sets up that ESP-IDF environment and runs the real
+tool, so IDEs and scripts can call the tools directly without 'idfmgr exec'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listShims(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing shims: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var shimsInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Create shims for the tools of all installed versions",
+	Long: `Create shims for the tools of all installed ESP-IDF versions in the shims directory,
+replacing existing ones. Run it again after installing a new version.`,
+	Args: cobra.NoArgs,
+	Example: `  idfmgr shims install
+  export PATH="$HOME/.esp/shims:$PATH"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := installShims(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error installing shims: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var shimExecCmd = &cobra.Command{
+	Use:                "shim-exec <tool> [args...]",
+	Short:              "Run a tool with the ESP-IDF environment of the current project (used by shims)",
+	Args:               cobra.MinimumNArgs(1),
+	Hidden:             true,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runShim(args[0], args[1:]); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			fmt.Fprintf(os.Stderr, "idfmgr: %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	shimsCmd.AddCommand(shimsInstallCmd)
+	rootCmd.AddCommand(shimsCmd)
+	rootCmd.AddCommand(shimExecCmd)
+}
+
+func getShimsDir() string {
+	return filepath.Join(getESPBase(), "shims")
+}
+
+func listShims() error {
+	shimsDir := getShimsDir()
+	entries, err := os.ReadDir(shimsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No shims installed. Create them with: idfmgr shims install")
+		return nil
+	}
+
+	fmt.Printf("Shims in %s:\n", shimsDir)
+	for _, entry := range entries {
+		fmt.Printf("  - %s\n", entry.Name())
+	}
+
+	if !shimsDirInPath() {
+		fmt.Printf("\nThe shims directory is not in your PATH. Add it with:\n  export PATH=\"%s:$PATH\"\n", shimsDir)
+	}
+	return nil
+}
+
+func installShims() error {
+	espBase := getESPBase()
+	installed, err := getInstalledVersions(espBase)
+	if err != nil {
+		return fmt.Errorf("failed to get installed versions: %w", err)
+	}
+	if len(installed) == 0 {
+		return fmt.Errorf("no ESP-IDF versions installed")
+	}
+
+	tools := make(map[string]bool)
+	for _, version := range installed {
+		idfPath := filepath.Join(espBase, version)
+		delta, err := loadEnvDelta(idfPath)
+		if err != nil {
+			fmt.Printf("Warning: Could not set up environment of %s, skipping: %v\n", version, err)
+			continue
+		}
+		for _, tool := range collectShimTools(idfPath, delta) {
+			tools[tool] = true
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate idfmgr executable: %w", err)
+	}
+
+	shimsDir := getShimsDir()
+	if err := os.RemoveAll(shimsDir); err != nil {
+		return fmt.Errorf("failed to remove old shims: %w", err)
+	}
+	if err := os.MkdirAll(shimsDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create shims directory: %w", err)
+	}
+
+	names := make([]string, 0, len(tools))
+	for tool := range tools {
+		names = append(names, tool)
+	}
+	sort.Strings(names)
+
+	for _, tool := range names {
+		if err := writeShim(shimsDir, exe, tool); err != nil {
+			return fmt.Errorf("failed to create shim for %s: %w", tool, err)
+		}
+	}
+
+	fmt.Printf("Created %d shim(s) in %s\n", len(names), shimsDir)
+	if !shimsDirInPath() {
+		fmt.Printf("\nAdd the shims directory to your PATH:\n  export PATH=\"%s:$PATH\"\n", shimsDir)
+	}
+	return nil
+}
+
+func collectShimTools(idfPath string, delta EnvDelta) []string {
+	pythonEnv := delta.Vars["IDF_PYTHON_ENV_PATH"]
+
+	var tools []string
+	for _, dir := range delta.PathPrepend {
+		inIDF := isSubPath(idfPath, dir)
+		inPythonEnv := pythonEnv != "" && isSubPath(pythonEnv, dir)
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				continue
+			}
+			switch {
+			case inIDF:
+				if name != "idf.py" {
+					continue
+				}
+			case inPythonEnv:
+				if !strings.HasPrefix(name, "esp") {
+					continue
+				}
+			}
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if info, err := entry.Info(); err != nil || info.Mode()&0o111 == 0 {
+				continue
+			}
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
+
+func writeShim(shimsDir, exe, tool string) error {
+	if runtime.GOOS == "windows" {
+		content := fmt.Sprintf("@\"%s\" shim-exec %s %%*\r\n", exe, tool)
+		return os.WriteFile(filepath.Join(shimsDir, tool+".cmd"), []byte(content), 0o644)
+	}
+
+	content := fmt.Sprintf("#!/bin/sh\nexec %s shim-exec %s \"$@\"\n", posixQuote(exe), posixQuote(tool))
+	return os.WriteFile(filepath.Join(shimsDir, tool), []byte(content), 0o755)
+}
+
+func shimsDirInPath() bool {
+	shimsDir := filepath.Clean(getShimsDir())
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(entry) == shimsDir {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveShimIDF() (string, error) {
+	_, idfPath, err := findProjectIDF()
+	return idfPath, err
+}
+
+func resolveShimTool(tool string) (string, []string, error) {
+	idfPath, err := resolveShimIDF()
+	if err != nil {
+		return "", nil, err
+	}
+
+	env, err := getESPIDFEnvironment(idfPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
+	}
+
+	path, err := lookPathInEnv(tool, env, getShimsDir())
+	if err != nil {
+		return "", nil, err
+	}
+	return path, env, nil
+}
+
+func runShim(tool string, args []string) error {
+	path, env, err := resolveShimTool(tool)
+	if err != nil {
+		return err
+	}
+
+	argv := append([]string{path}, args...)
+	if strings.HasSuffix(path, ".py") && runtime.GOOS == "windows" {
+		python, err := lookPathInEnv("python", env, getShimsDir())
+		if err != nil {
+			return err
+		}
+		argv = append([]string{python}, argv...)
+	}
+
+	if runtime.GOOS != "windows" {
+		return syscall.Exec(argv[0], argv, env)
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func lookPathInEnv(name string, env []string, skipDir string) (string, error) {
+	exts := []string{""}
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		exts = strings.Split(strings.ToLower(envListToMap(env)["PATHEXT"]), ";")
+		exts = append(exts, ".py")
+	}
+
+	skipDir = filepath.Clean(skipDir)
+	for _, dir := range filepath.SplitList(envListToMap(env)["PATH"]) {
+		if dir == "" || filepath.Clean(dir) == skipDir {
+			continue
+		}
+		for _, ext := range exts {
+			candidate := filepath.Join(dir, name+ext)
+			info, err := os.Stat(candidate)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if runtime.GOOS != "windows" && info.Mode()&0o111 == 0 {
+				continue
+			}
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in the ESP-IDF environment", name)
+}
+
+func isSubPath(parent, path string) bool {
+	rel, err := filepath.Rel(parent, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	sizeReportFile         = "idfmgr-size.json"
+	sizePreviousReportFile = "idfmgr-size.prev.json"
+	sizeBaselineFile       = "size-baseline.json"
+)
+
+var (
+	sizeToolchain    string
+	sizeProfile      string
+	sizeBaseline     string
+	sizeSaveBaseline bool
+	sizeTop          int
+)
+
+type SizeReport struct {
+	Target     string                 `json:"target"`
+	ImageSize  int64                  `json:"image_size"`
+	Memory     map[string]MemoryUsage `json:"memory"`
+	Components map[string]int64       `json:"components"`
+	AppSHA256  string                 `json:"app_sha256,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type MemoryUsage struct {
+	Used  int64 `json:"used"`
+	Total int64 `json:"total"`
+}
+
+var sizeCmd = &cobra.Command{
+	Use:   "size",
+	Short: "Show the firmware size of the last build",
+	Long: `Show the flash and RAM use of the last build per memory type and per component, using
+the ESP-IDF size tool (ESP-IDF v5.1 or newer). Sizes are compared with the saved baseline
+(size-baseline.json in the project) or else with the previous build, and the command fails
+when the limits in the [size] section of idfmgr.toml are exceeded.`,
+	Args: cobra.NoArgs,
+	Example: `  idfmgr size
+  idfmgr size --profile release --top 0
+  idfmgr size --save-baseline
+  idfmgr size --baseline ci/size-baseline.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := showSize(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	sizeCmd.Flags().StringVar(&sizeToolchain, "toolchain", "", "Report the build of this toolchain: gcc or clang (default: from idfmgr.toml, or gcc)")
+	sizeCmd.Flags().StringVar(&sizeProfile, "profile", "", "Report the build of a profile declared in idfmgr.toml")
+	sizeCmd.Flags().StringVar(&sizeBaseline, "baseline", "", "Compare with this baseline file (default: size-baseline.json in the project)")
+	sizeCmd.Flags().BoolVar(&sizeSaveBaseline, "save-baseline", false, "Save the sizes of this build as the project's baseline")
+	sizeCmd.Flags().IntVar(&sizeTop, "top", 10, "Number of components to show, 0 for all")
+	sizeCmd.RegisterFlagCompletionFunc("toolchain", completeToolchains)
+	sizeCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	rootCmd.AddCommand(sizeCmd)
+}
+
+func showSize() error {
+	_, idfPath, err := resolveProjectIDF()
+	if err != nil {
+		return err
+	}
+
+	manifest, err := getProjectManifest()
+	if err != nil {
+		return err
+	}
+
+	projectDir := getProjectDir()
+	config, err := resolveBuildConfig(manifest, sizeProfile, sizeToolchain, projectDir)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, config.BuildDir)); os.IsNotExist(err) {
+		return fmt.Errorf("%s build directory not found. Build first with: %s", config.BuildDir, config.buildCommand())
+	}
+
+	env, err := getESPIDFEnvironment(idfPath)
+	if err != nil {
+		return fmt.Errorf("failed to setup ESP-IDF environment: %w", err)
+	}
+	env = config.applyEnv(applyManifestEnv(env, manifest))
+
+	report, previous, err := updateSizeReport(idfPath, env, projectDir, config)
+	if err != nil {
+		return err
+	}
+
+	baselinePath := sizeBaseline
+	if baselinePath == "" {
+		baselinePath = filepath.Join(projectDir, sizeBaselineFile)
+	}
+
+	if sizeSaveBaseline {
+		if err := saveSizeBaseline(baselinePath, config.BuildDir, report); err != nil {
+			return fmt.Errorf("failed to save baseline: %w", err)
+		}
+		printSizeReport(report, nil, "", sizeTop)
+		fmt.Printf("\nSaved baseline for %s/ to %s\n", config.BuildDir, baselinePath)
+		return nil
+	}
+
+	reference, referenceName := previous, "previous build"
+	if baseline, err := loadSizeBaseline(baselinePath, config.BuildDir); err == nil {
+		reference, referenceName = baseline, "baseline"
+	} else if sizeBaseline != "" {
+		return fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	printSizeReport(report, reference, referenceName, sizeTop)
+	return checkSizeLimits(manifest, report, reference, referenceName)
+}
+
+func reportBuildSize(manifest *ProjectManifest, idfPath string, env []string, projectDir string, config *BuildConfig) error {
+	report, previous, err := updateSizeReport(idfPath, env, projectDir, config)
+	if err != nil {
+		fmt.Printf("Warning: Could not compute the firmware size: %v\n", err)
+		return nil
+	}
+
+	reference, referenceName := previous, "previous build"
+	if baseline, err := loadSizeBaseline(filepath.Join(projectDir, sizeBaselineFile), config.BuildDir); err == nil {
+		reference, referenceName = baseline, "baseline"
+	}
+
+	printSizeReport(report, reference, referenceName, -1)
+	return checkSizeLimits(manifest, report, reference, referenceName)
+}
+
+func updateSizeReport(idfPath string, env []string, projectDir string, config *BuildConfig) (*SizeReport, *SizeReport, error) {
+	buildDir := filepath.Join(projectDir, config.BuildDir)
+	reportPath := filepath.Join(buildDir, sizeReportFile)
+	previousPath := filepath.Join(buildDir, sizePreviousReportFile)
+
+	appBin, err := getAppBinaryPath(buildDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	appHash, err := sha256File(appBin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, _ := readSizeReport(reportPath)
+	if current != nil && current.AppSHA256 == appHash {
+		previous, _ := readSizeReport(previousPath)
+		return current, previous, nil
+	}
+
+	report, err := collectSizeReport(idfPath, env, projectDir, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.AppSHA256 = appHash
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	if current != nil {
+		if err := os.Rename(reportPath, previousPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := writeFileAtomic(reportPath, data, 0o644); err != nil {
+		return nil, nil, err
+	}
+	return report, current, nil
+}
+
+func readSizeReport(path string) (*SizeReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report SizeReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func collectSizeReport(idfPath string, env []string, projectDir string, config *BuildConfig) (*SizeReport, error) {
+	var summary struct {
+		Target      string `json:"target"`
+		ImageSize   int64  `json:"image_size"`
+		MemoryTypes map[string]struct {
+			Size int64 `json:"size"`
+			Used int64 `json:"used"`
+		} `json:"memory_types"`
+	}
+	if err := runSizeTool(idfPath, env, projectDir, config, "size", &summary); err != nil {
+		return nil, err
+	}
+
+	var archives map[string]struct {
+		AbbrevName string `json:"abbrev_name"`
+		Size       int64  `json:"size"`
+	}
+	if err := runSizeTool(idfPath, env, projectDir, config, "size-components", &archives); err != nil {
+		return nil, err
+	}
+
+	report := &SizeReport{
+		Target:     summary.Target,
+		ImageSize:  summary.ImageSize,
+		Memory:     make(map[string]MemoryUsage),
+		Components: make(map[string]int64),
+		CreatedAt:  time.Now(),
+	}
+	for name, usage := range summary.MemoryTypes {
+		report.Memory[name] = MemoryUsage{Used: usage.Used, Total: usage.Size}
+	}
+	for archive, usage := range archives {
+		name := usage.AbbrevName
+		if name == "" {
+			name = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(archive), "lib"), ".a")
+		}
+		report.Components[name] += usage.Size
+	}
+	return report, nil
+}
+
+func runSizeTool(idfPath string, env []string, projectDir string, config *BuildConfig, action string, result interface{}) error {
+	output, err := os.CreateTemp("", "idfmgr-size-*.json")
+	if err != nil {
+		return err
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	python, err := lookPathInEnv("python", env, "")
+	if err != nil {
+		return err
+	}
+
+	var log bytes.Buffer
+	cmd := exec.Command(python, filepath.Join(idfPath, "tools", "idf.py"), "-B", config.BuildDir,
+		action, "--format", "json2", "--output-file", output.Name())
+	cmd.Dir = projectDir
+	cmd.Env = env
+	cmd.Stdout = &log
+	cmd.Stderr = &log
+
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+		return fmt.Errorf("idf.py %s failed (size reports need ESP-IDF v5.1 or newer): %w: %s", action, err, lines[len(lines)-1])
+	}
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to parse idf.py %s output: %w", action, err)
+	}
+	return nil
+}
+
+func loadSizeBaseline(path, buildDir string) (*SizeReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	baselines := make(map[string]*SizeReport)
+	if err := json.Unmarshal(data, &baselines); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	baseline, ok := baselines[buildDir]
+	if !ok {
+		return nil, fmt.Errorf("%s has no baseline for %s/", path, buildDir)
+	}
+	return baseline, nil
+}
+
+func saveSizeBaseline(path, buildDir string, report *SizeReport) error {
+	baselines := make(map[string]*SizeReport)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &baselines); err != nil {
+			return fmt.Errorf("invalid baseline %s: %w", path, err)
+		}
+	}
+	baselines[buildDir] = report
+
+	data, err := json.MarshalIndent(baselines, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func printSizeReport(report, reference *SizeReport, referenceName string, top int) {
+	fmt.Printf("\nFirmware Size (%s)\n", report.Target)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━")
+
+	imageLine := fmt.Sprintf("Image size: %s", formatBytes(report.ImageSize))
+	if reference != nil {
+		imageLine += fmt.Sprintf(" (%s vs %s)", formatSizeDelta(report.ImageSize-reference.ImageSize), referenceName)
+	}
+	fmt.Println(imageLine)
+
+	names := make([]string, 0, len(report.Memory))
+	for name := range report.Memory {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("\n%-20s %10s %10s %6s  %s\n", "MEMORY TYPE", "USED", "TOTAL", "USE%", "CHANGE")
+	for _, name := range names {
+		usage := report.Memory[name]
+		total, percent := "-", "-"
+		if usage.Total > 0 {
+			total = formatBytes(usage.Total)
+			percent = fmt.Sprintf("%.1f%%", float64(usage.Used)*100/float64(usage.Total))
+		}
+		change := ""
+		if reference != nil {
+			change = formatSizeDelta(usage.Used - reference.Memory[name].Used)
+		}
+		fmt.Println(strings.TrimRight(fmt.Sprintf("%-20s %10s %10s %6s  %s", name, formatBytes(usage.Used), total, percent, change), " "))
+	}
+
+	if top < 0 || len(report.Components) == 0 {
+		return
+	}
+
+	components := make([]string, 0, len(report.Components))
+	for name := range report.Components {
+		components = append(components, name)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if report.Components[components[i]] != report.Components[components[j]] {
+			return report.Components[components[i]] > report.Components[components[j]]
+		}
+		return components[i] < components[j]
+	})
+	if top > 0 && len(components) > top {
+		components = components[:top]
+	}
+
+	fmt.Printf("\n%-20s %10s  %s\n", "COMPONENT", "SIZE", "CHANGE")
+	for _, name := range components {
+		change := ""
+		if reference != nil {
+			change = formatSizeDelta(report.Components[name] - reference.Components[name])
+		}
+		fmt.Println(strings.TrimRight(fmt.Sprintf("%-20s %10s  %s", name, formatBytes(report.Components[name]), change), " "))
+	}
+}
+
+func formatSizeDelta(delta int64) string {
+	switch {
+	case delta > 0:
+		return "+" + formatBytes(delta)
+	case delta < 0:
+		return "-" + formatBytes(-delta)
+	}
+	return "±0"
+}
+
+func checkSizeLimits(manifest *ProjectManifest, report, reference *SizeReport, referenceName string) error {
+	if manifest == nil {
+		return nil
+	}
+
+	var exceeded []string
+	if manifest.Size.Image != "" {
+		limit, _ := parseBytes(manifest.Size.Image)
+		if report.ImageSize > limit {
+			exceeded = append(exceeded, fmt.Sprintf("image size %s exceeds %s", formatBytes(report.ImageSize), formatBytes(limit)))
+		}
+	}
+	if manifest.Size.Growth != "" && reference != nil {
+		limit, _ := parseBytes(manifest.Size.Growth)
+		if growth := report.ImageSize - reference.ImageSize; growth > limit {
+			exceeded = append(exceeded, fmt.Sprintf("image grew by %s since the %s, more than %s", formatBytes(growth), referenceName, formatBytes(limit)))
+		}
+	}
+	for name, value := range manifest.Size.Memory {
+		limit, _ := parseBytes(value)
+		usage, ok := report.Memory[name]
+		if !ok {
+			fmt.Printf("Warning: Unknown memory type %q in [size.memory] of %s\n", name, manifestFile)
+			continue
+		}
+		if usage.Used > limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s use %s exceeds %s", name, formatBytes(usage.Used), formatBytes(limit)))
+		}
+	}
+
+	if len(exceeded) == 0 {
+		return nil
+	}
+	sort.Strings(exceeded)
+	return fmt.Errorf("size limits exceeded:\n  - %s", strings.Join(exceeded, "\n  - "))
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultToolchain = "gcc"
+
+type Toolchain struct {
+	Name      string
+	Label     string
+	CMakeArgs []string
+	DirSuffix string
+	Tools     []string
+	Install   string
+}
+
+var toolchains = []Toolchain{
+	{
+		Name:    "gcc",
+		Label:   "GCC",
+		Tools:   []string{"{prefix}-gcc"},
+		Install: "python3 $IDF_PATH/tools/idf_tools.py install --targets={target}",
+	},
+	{
+		Name:      "clang",
+		Label:     "Clang",
+		CMakeArgs: []string{"-DIDF_TOOLCHAIN=clang"},
+		DirSuffix: "-clang",
+		Tools:     []string{"clang"},
+		Install:   "python3 $IDF_PATH/tools/idf_tools.py install esp-clang",
+	},
+}
+
+func getToolchain(name string) (*Toolchain, error) {
+	for i := range toolchains {
+		if toolchains[i].Name == name {
+			return &toolchains[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported toolchain %q (supported: %s)", name, strings.Join(toolchainNames(), ", "))
+}
+
+func toolchainNames() []string {
+	names := make([]string, 0, len(toolchains))
+	for _, toolchain := range toolchains {
+		names = append(names, toolchain.Name)
+	}
+	return names
+}
+
+func toolchainFlag(toolchain string, clang bool) (string, error) {
+	if !clang {
+		return toolchain, nil
+	}
+	if toolchain != "" && toolchain != "clang" {
+		return "", fmt.Errorf("--clang cannot be combined with --toolchain %s", toolchain)
+	}
+	return "clang", nil
+}
+
+func manifestToolchain(manifest *ProjectManifest) string {
+	if manifest == nil || manifest.Toolchain == "" {
+		return defaultToolchain
+	}
+	return manifest.Toolchain
+}
+
+func toolchainTarget(target, projectDir string) string {
+	if target != "" {
+		return target
+	}
+	if target := readProjectTarget(projectDir); target != "" {
+		return target
+	}
+	return "esp32"
+}
+
+func gccPrefix(target string) string {
+	switch target {
+	case "esp32", "esp32s2", "esp32s3":
+		return "xtensa-" + target + "-elf"
+	default:
+		return "riscv32-esp-elf"
+	}
+}
+
+func (t *Toolchain) installHint(target string) string {
+	return strings.ReplaceAll(t.Install, "{target}", target)
+}
+
+func (t *Toolchain) checkInstalled(env []string, target string) error {
+	for _, tool := range t.Tools {
+		tool = strings.ReplaceAll(tool, "{prefix}", gccPrefix(target))
+		if _, err := lookPathInEnv(tool, env, ""); err != nil {
+			return fmt.Errorf("%s toolchain for %s is not installed for this ESP-IDF (%s not found). Install it with: %s", t.Label, target, tool, t.installHint(target))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const trashTimeFormat = "20060102T150405"
+
+type TrashEntry struct {
+	Version   string
+	RemovedAt time.Time
+	Path      string
+}
+
+var (
+	trashOlderThan int
+	trashMaxSize   string
+	trashForce     bool
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List ESP-IDF versions in the trash",
+	Long: `Show removed ESP-IDF versions that are kept in the trash inside ESP_BASE.
+Trashed versions can be brought back with 'idfmgr restore <version>'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := listTrash(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing trash: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Permanently delete versions from the trash",
+	Long: `Permanently delete trashed ESP-IDF versions. Without flags the whole trash is emptied,
+otherwise only entries older than --older-than days or beyond --max-size are deleted.`,
+	Args: cobra.NoArgs,
+	Example: `  idfmgr trash empty
+  idfmgr trash empty --older-than 14
+  idfmgr trash empty --max-size 10G --force`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := emptyTrash(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error emptying trash: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:     "restore <version>",
+	Short:   "Restore a removed ESP-IDF version from the trash",
+	Args:    cobra.ExactArgs(1),
+	Example: `  idfmgr restore v5.1.2`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := restoreVersion(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring version %s: %v\n", args[0], err)
+			os.Exit(1)
+		}
+	},
+	ValidArgsFunction: completeFirstArg(completeTrashedVersions),
+}
+
+func init() {
+	trashEmptyCmd.Flags().IntVar(&trashOlderThan, "older-than", 0, "Only delete entries removed more than N days ago")
+	trashEmptyCmd.Flags().StringVar(&trashMaxSize, "max-size", "", "Delete the oldest entries until the trash is below this size (e.g. 10G)")
+	trashEmptyCmd.Flags().BoolVarP(&trashForce, "force", "f", false, "Skip confirmation prompts")
+	trashCmd.AddCommand(trashEmptyCmd)
+	rootCmd.AddCommand(trashCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func listTrash() error {
+	entries, err := getTrashEntries()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Trash is empty.")
+		return nil
+	}
+
+	fmt.Printf("%-15s %-20s %10s\n", "VERSION", "REMOVED", "SIZE")
+	fmt.Printf("%s\n", strings.Repeat("-", 50))
+
+	var total int64
+	for _, entry := range entries {
+		size, err := getDirSize(entry.Path)
+		if err != nil {
+			fmt.Printf("%-15s %-20s %10s\n", entry.Version, entry.RemovedAt.Format("2006-01-02 15:04"), "?")
+			continue
+		}
+		total += size
+		fmt.Printf("%-15s %-20s %10s\n", entry.Version, entry.RemovedAt.Format("2006-01-02 15:04"), formatBytes(size))
+	}
+
+	fmt.Printf("\nTotal: %d version(s), %s\n", len(entries), formatBytes(total))
+	fmt.Println("Restore with: idfmgr restore <version>")
+	return nil
+}
+
+func emptyTrash() error {
+	var maxSize int64 = -1
+	if trashMaxSize != "" {
+		var err error
+		if maxSize, err = parseBytes(trashMaxSize); err != nil {
+			return fmt.Errorf("invalid --max-size: %w", err)
+		}
+	}
+
+	entries, err := getTrashEntries()
+	if err != nil {
+		return err
+	}
+
+	var toDelete []TrashEntry
+	if trashOlderThan <= 0 && maxSize < 0 {
+		toDelete = entries
+	} else {
+		toDelete = selectTrashForDeletion(entries, trashOlderThan, maxSize)
+	}
+
+	if len(toDelete) == 0 {
+		fmt.Println("Nothing to delete.")
+		return nil
+	}
+
+	fmt.Printf("Will permanently delete %d trashed version(s):\n", len(toDelete))
+	for _, entry := range toDelete {
+		fmt.Printf("  - %s (removed %s)\n", entry.Version, entry.RemovedAt.Format("2006-01-02 15:04"))
+	}
+
+	if !trashForce {
+		confirmed, err := confirm("\nAre you sure you want to proceed?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Operation cancelled.")
+			return nil
+		}
+	}
+
+	freed := purgeTrashEntries(toDelete)
+	fmt.Printf("\nFreed %s\n", formatBytes(freed))
+	return nil
+}
+
+func restoreVersion(version string) error {
+	entries, err := getTrashEntries()
+	if err != nil {
+		return err
+	}
+
+	var latest *TrashEntry
+	for i := range entries {
+		if entries[i].Version == version && (latest == nil || entries[i].RemovedAt.After(latest.RemovedAt)) {
+			latest = &entries[i]
+		}
+	}
+
+	if latest == nil {
+		return fmt.Errorf("version %s is not in the trash", version)
+	}
+
+	installPath := filepath.Join(getESPBase(), version)
+	if _, err := os.Stat(installPath); err == nil {
+		return fmt.Errorf("version %s is already installed at %s", version, installPath)
+	}
+
+	if err := os.Rename(latest.Path, installPath); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", version, err)
+	}
+
+	fmt.Printf("Restored ESP-IDF version %s to %s\n", version, installPath)
+	return nil
+}
+
+func getTrashPath() string {
+	return filepath.Join(getESPBase(), ".trash")
+}
+
+func moveToTrash(espBase, version string) (string, error) {
+	trashPath := getTrashPath()
+	if err := os.MkdirAll(trashPath, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	base := fmt.Sprintf("%s@%s", version, time.Now().Format(trashTimeFormat))
+	name := base
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(filepath.Join(trashPath, name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	dest := filepath.Join(trashPath, name)
+	if err := os.Rename(filepath.Join(espBase, version), dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+func getTrashEntries() ([]TrashEntry, error) {
+	dirEntries, err := os.ReadDir(getTrashPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash directory: %w", err)
+	}
+
+	var entries []TrashEntry
+	for _, dirEntry := range dirEntries {
+		i := strings.LastIndex(dirEntry.Name(), "@")
+		if !dirEntry.IsDir() || i <= 0 {
+			continue
+		}
+
+		stamp, _, _ := strings.Cut(dirEntry.Name()[i+1:], "-")
+		removedAt, err := time.ParseInLocation(trashTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, TrashEntry{
+			Version:   dirEntry.Name()[:i],
+			RemovedAt: removedAt,
+			Path:      filepath.Join(getTrashPath(), dirEntry.Name()),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].RemovedAt.Equal(entries[j].RemovedAt) {
+			return entries[i].RemovedAt.Before(entries[j].RemovedAt)
+		}
+		if len(entries[i].Path) != len(entries[j].Path) {
+			return len(entries[i].Path) < len(entries[j].Path)
+		}
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries, nil
+}
+
+func selectTrashForDeletion(entries []TrashEntry, olderThanDays int, maxSize int64) []TrashEntry {
+	var toDelete []TrashEntry
+	var remaining []TrashEntry
+
+	cutoff := time.Now().AddDate(0, 0, -olderThanDays)
+	for _, entry := range entries {
+		if olderThanDays > 0 && entry.RemovedAt.Before(cutoff) {
+			toDelete = append(toDelete, entry)
+		} else {
+			remaining = append(remaining, entry)
+		}
+	}
+
+	if maxSize < 0 {
+		return toDelete
+	}
+
+	sizes := make([]int64, len(remaining))
+	var total int64
+	for i, entry := range remaining {
+		sizes[i], _ = getDirSize(entry.Path)
+		total += sizes[i]
+	}
+
+	for i := 0; i < len(remaining) && total > maxSize; i++ {
+		toDelete = append(toDelete, remaining[i])
+		total -= sizes[i]
+	}
+
+	return toDelete
+}
+
+func purgeTrashEntries(entries []TrashEntry) int64 {
+	var freed int64
+	for _, entry := range entries {
+		size, _ := getDirSize(entry.Path)
+		if err := os.RemoveAll(entry.Path); err != nil {
+			fmt.Printf("Failed to delete %s: %v\n", entry.Version, err)
+			continue
+		}
+		freed += size
+	}
+	return freed
+}
+
+func enforceTrashLimits(force bool, keep []string) {
+	maxAge := 30
+	if value := os.Getenv("IDFMGR_TRASH_MAX_AGE"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			fmt.Printf("Warning: Ignoring invalid IDFMGR_TRASH_MAX_AGE %q\n", value)
+		} else {
+			maxAge = days
+		}
+	}
+
+	var maxSize int64 = -1
+	if value := os.Getenv("IDFMGR_TRASH_MAX_SIZE"); value != "" {
+		size, err := parseBytes(value)
+		if err != nil {
+			fmt.Printf("Warning: Ignoring invalid IDFMGR_TRASH_MAX_SIZE %q\n", value)
+		} else {
+			maxSize = size
+		}
+	}
+
+	entries, err := getTrashEntries()
+	if err != nil || len(entries) == 0 {
+		return
+	}
+
+	var toDelete []TrashEntry
+	kept := false
+	for _, entry := range selectTrashForDeletion(entries, maxAge, maxSize) {
+		if containsString(keep, entry.Path) {
+			kept = true
+			continue
+		}
+		toDelete = append(toDelete, entry)
+	}
+	if kept {
+		fmt.Println("\nThe versions just removed stay in the trash so they can be restored, even though it exceeds IDFMGR_TRASH_MAX_SIZE.")
+	}
+	if len(toDelete) == 0 {
+		return
+	}
+
+	fmt.Printf("\n%d trash entry(ies) exceed the trash limits (IDFMGR_TRASH_MAX_AGE, IDFMGR_TRASH_MAX_SIZE):\n", len(toDelete))
+	for _, entry := range toDelete {
+		fmt.Printf("  - %s (removed %s)\n", entry.Version, entry.RemovedAt.Format("2006-01-02 15:04"))
+	}
+
+	if !force {
+		confirmed, err := confirm("\nPermanently delete them now?")
+		if err != nil || !confirmed {
+			fmt.Println("Kept them in the trash. Delete them later with: idfmgr trash empty")
+			return
+		}
+	}
+
+	freed := purgeTrashEntries(toDelete)
+	fmt.Printf("Emptied %d old trash entry(ies), freed %s\n", len(toDelete), formatBytes(freed))
+}
+
+func parseBytes(value string) (int64, error) {
+	s := strings.TrimSpace(strings.ToUpper(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	return int64(n * float64(multiplier)), nil
+}
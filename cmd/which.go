@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var whichCmd = &cobra.Command{
+	Use:   "which <tool>",
+	Short: "Show the real binary a shim would run",
+	Long:  `Show the path of the tool that a shim would run for the ESP-IDF version of the current project`,
+	Args:  cobra.ExactArgs(1),
+	Example: `  idfmgr which idf.py
+  idfmgr which xtensa-esp32-elf-gcc`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _, err := resolveShimTool(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whichCmd)
+}
@@ -1,6 +1,9 @@
 module github.com/Dwarf1er/idfmgr
 
-require github.com/spf13/cobra v1.10.1
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/spf13/cobra v1.10.1
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect